@@ -4,10 +4,13 @@ go 1.23.0
 
 require (
 	github.com/gliderlabs/ssh v0.3.7
-	golang.org/x/crypto v0.29.0 // indirect
+	github.com/pkg/sftp v1.13.7
+	golang.org/x/crypto v0.29.0
+	golang.org/x/term v0.26.0
 )
 
 require (
 	github.com/anmitsu/go-shlex v0.0.0-20200514113438-38f4b401e2be // indirect
+	github.com/kr/fs v0.1.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
 )
@@ -0,0 +1,292 @@
+package audit
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/pkg/logger"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPath 登录历史文件, 每行一条 JSON 记录, 只追加不修改
+const DefaultPath = "~/.ssh/ssp_history.jsonl"
+
+// Env 修改历史文件路径, 设置为 off 时不记录
+const Env = "SSP_HISTORY"
+
+// 登录结果
+const (
+	StatusStarted = "started" // 开始记录, 没有对应的结束记录时会话仍在进行或 ssp 被中止
+	StatusOK      = "ok"      // 会话正常结束, 退出码为 0
+	StatusExit    = "exit"    // 会话结束, 退出码不为 0
+	StatusFailed  = "failed"  // 连接或认证失败
+	StatusHandoff = "handoff" // 交给 ssh/sshpass 进程, 结束时间和退出码未知
+)
+
+// Entry 一次登录尝试, 开始和结束时各追加一条记录, 通过 ID 对应
+type Entry struct {
+	ID       string     `json:"id,omitempty"`
+	Host     string     `json:"host"`
+	Hostname string     `json:"hostname"`
+	User     string     `json:"user"`
+	Port     uint16     `json:"port"`
+	Command  string     `json:"command"` // ssh, sftp, exec, cp
+	TTY      string     `json:"tty,omitempty"`
+	PID      int        `json:"pid"`
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end,omitempty"`
+	Status   string     `json:"status"`
+	ExitCode *int       `json:"exit_code,omitempty"`
+	Error    string     `json:"error,omitempty"`
+}
+
+// Path 返回历史文件路径, 为空时不记录
+func Path() string {
+	p := os.Getenv(Env)
+	if strings.EqualFold(p, "off") {
+		return ""
+	}
+	if p == "" {
+		p = DefaultPath
+	}
+	return config.AbsPath(p)
+}
+
+// Begin 写入登录的开始记录, 结束时调用 Finish 或 Fail 写入结束记录,
+// 这样连接卡住或 ssp 被终止时也能看到这次尝试
+func Begin(cfg *config.SSHConfig, command string) *Entry {
+	e := &Entry{
+		ID:       newID(),
+		Host:     cfg.Host,
+		Hostname: cfg.Hostname,
+		User:     cfg.User,
+		Port:     cfg.PortOrDefault(),
+		Command:  command,
+		TTY:      tty(),
+		PID:      os.Getpid(),
+		Start:    time.Now().UTC(),
+		Status:   StatusStarted,
+	}
+	e.write()
+	return e
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Finish 记录会话的退出码
+func (e *Entry) Finish(code int, err error) {
+	e.end()
+	e.ExitCode = &code
+	e.Status = StatusOK
+	if code != 0 {
+		e.Status = StatusExit
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+	e.write()
+}
+
+// Fail 记录连接或认证失败的原因
+func (e *Entry) Fail(err error) {
+	e.end()
+	e.Status = StatusFailed
+	e.Error = err.Error()
+	e.write()
+}
+
+func (e *Entry) end() {
+	end := time.Now().UTC()
+	e.End = &end
+}
+
+// Handoff 登录交给外部进程, 只能记录开始时间
+func (e *Entry) Handoff() {
+	e.Status = StatusHandoff
+	e.write()
+}
+
+// tty 当前终端的设备名, 没有 /proc 的系统上使用 SSH_TTY
+func tty() string {
+	if name, err := os.Readlink("/proc/self/fd/0"); err == nil && strings.HasPrefix(name, "/dev/") {
+		return strings.TrimPrefix(name, "/dev/")
+	}
+	return strings.TrimPrefix(os.Getenv("SSH_TTY"), "/dev/")
+}
+
+// write 写入历史文件失败不影响登录, 只输出警告
+func (e *Entry) write() {
+	p := Path()
+	if p == "" {
+		return
+	}
+	if err := Append(p, e); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: writing login history: %v\n", err)
+	}
+}
+
+// Append 追加一条记录, 每条记录一次写入, 多个 ssp 同时写也不会交错
+func Append(p string, e *Entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Filter 查询条件, 零值表示不限制
+type Filter struct {
+	Host   string // Host 或 HostName, 支持通配符
+	Since  time.Time
+	Until  time.Time
+	Failed bool // 只显示失败的记录
+}
+
+func (f *Filter) Match(e *Entry) bool {
+	if f.Host != "" && !globMatch(f.Host, e.Host) && !globMatch(f.Host, e.Hostname) {
+		return false
+	}
+	if !f.Since.IsZero() && e.Start.Before(f.Since) {
+		return false
+	}
+	if !f.Until.IsZero() && !e.Start.Before(f.Until) {
+		return false
+	}
+	if f.Failed && e.Status != StatusFailed && e.Status != StatusExit {
+		return false
+	}
+	return true
+}
+
+func globMatch(pattern, s string) bool {
+	ok, err := path.Match(pattern, s)
+	return ok && err == nil
+}
+
+// Read 读取满足条件的记录, 按开始时间顺序; 结束记录替换 ID 相同的开始记录
+func Read(r io.Reader, f *Filter) ([]Entry, error) {
+	var entries []Entry
+	started := map[string]int{}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var e Entry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			// 写入中断的行跳过, 不影响其他记录
+			logger.Logger.Printf("Skipping invalid history line %d: %v", line, err)
+			continue
+		}
+		if i, ok := started[e.ID]; ok && e.ID != "" {
+			entries[i] = e
+			continue
+		}
+		if e.ID != "" {
+			started[e.ID] = len(entries)
+		}
+		entries = append(entries, e)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	// 结束记录中才有结果, 合并后再过滤
+	matched := entries[:0]
+	for _, e := range entries {
+		if f.Match(&e) {
+			matched = append(matched, e)
+		}
+	}
+	return matched, nil
+}
+
+// ReadFile 读取历史文件
+func ReadFile(p string, f *Filter) ([]Entry, error) {
+	file, err := os.Open(p)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return Read(file, f)
+}
+
+// ParseTime 解析时间范围, 支持相对时间 (30m, 24h, 7d) 和日期 (2024-01-02, 2024-01-02 15:04, RFC3339)
+func ParseTime(s string, now time.Time) (time.Time, error) {
+	if strings.HasSuffix(s, "d") {
+		var days int
+		if _, err := fmt.Sscanf(s, "%dd", &days); err == nil && fmt.Sprintf("%dd", days) == s {
+			return now.AddDate(0, 0, -days), nil
+		}
+	}
+	if d, err := time.ParseDuration(s); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q, expected e.g. 24h, 7d, 2024-01-02 or 2024-01-02 15:04", s)
+}
+
+// Print 输出历史记录, 时间按本地时区显示
+func Print(w io.Writer, entries []Entry) {
+	hostWidth := len("HOST")
+	for _, e := range entries {
+		hostWidth = max(hostWidth, len(e.Host))
+	}
+	fmt.Fprintf(w, "%-19s  %-9s  %-*s  %-30s  %-5s  %-12s  %s\n", "START", "DURATION", hostWidth, "HOST", "USER@HOSTNAME", "CMD", "TTY", "STATUS")
+	for _, e := range entries {
+		duration := "-"
+		if e.End != nil {
+			duration = e.End.Sub(e.Start).Round(time.Second).String()
+		}
+		tty := e.TTY
+		if tty == "" {
+			tty = "-"
+		}
+		status := e.Status
+		if e.ExitCode != nil && *e.ExitCode != 0 {
+			status = fmt.Sprintf("%s %d", status, *e.ExitCode)
+		}
+		if e.Error != "" {
+			status += ": " + e.Error
+		}
+		fmt.Fprintf(w, "%-19s  %-9s  %-*s  %-30s  %-5s  %-12s  %s\n",
+			e.Start.Local().Format("2006-01-02 15:04:05"), duration, hostWidth, e.Host,
+			fmt.Sprintf("%s@%s", e.User, e.Hostname), e.Command, tty, status)
+	}
+}
@@ -0,0 +1,111 @@
+package audit
+
+import (
+	"bytes"
+	"errors"
+	"golang_ssp/golang_ssp/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ssh", "history.jsonl")
+	t.Setenv(Env, path)
+
+	cfg := &config.SSHConfig{Host: "node1", Hostname: "10.0.0.1", User: "root"}
+	Begin(cfg, "ssh").Finish(0, nil)
+	Begin(cfg, "ssh").Finish(130, nil)
+	Begin(&config.SSHConfig{Host: "db1", Hostname: "10.0.0.2", User: "admin"}, "ssh").Fail(errors.New("unable to authenticate"))
+	// 没有结束记录, 如 ssp 被终止
+	Begin(cfg, "exec")
+
+	// 写入中断的行不影响其他记录
+	file, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0600)
+	file.WriteString(`{"host":"broken`)
+	file.Close()
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	// 开始和结束各一条记录
+	content, _ := os.ReadFile(path)
+	if lines := strings.Count(string(content), "\n"); lines != 7 {
+		t.Errorf("Expected 7 records, got %d:\n%s", lines, content)
+	}
+
+	entries, err := ReadFile(path, &Filter{})
+	if err != nil || len(entries) != 4 {
+		t.Fatalf("Expected 4 entries, got %d: %v", len(entries), err)
+	}
+	if entries[0].Status != StatusOK || *entries[0].ExitCode != 0 || entries[0].End == nil || entries[0].Port != 22 {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+	if entries[1].Status != StatusExit || *entries[1].ExitCode != 130 {
+		t.Errorf("Unexpected entry %+v", entries[1])
+	}
+	if entries[2].Status != StatusFailed || entries[2].Error != "unable to authenticate" || entries[2].ExitCode != nil {
+		t.Errorf("Unexpected entry %+v", entries[2])
+	}
+	if entries[3].Status != StatusStarted || entries[3].End != nil || entries[3].Command != "exec" {
+		t.Errorf("Unexpected entry %+v", entries[3])
+	}
+
+	testCases := []struct {
+		filter   Filter
+		expected int
+	}{
+		{Filter{Host: "node1"}, 3},
+		{Filter{Host: "10.0.0.*"}, 4},
+		{Filter{Failed: true}, 2},
+		{Filter{Since: time.Now().Add(-time.Hour)}, 4},
+		{Filter{Until: time.Now().Add(-time.Hour)}, 0},
+	}
+	for _, tc := range testCases {
+		entries, _ := ReadFile(path, &tc.filter)
+		if len(entries) != tc.expected {
+			t.Errorf("Filter %+v: expected %d entries, got %d", tc.filter, tc.expected, len(entries))
+		}
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, entries)
+	if !strings.Contains(buf.String(), "root@10.0.0.1") || !strings.Contains(buf.String(), "failed: unable to authenticate") || !strings.Contains(buf.String(), "started") {
+		t.Errorf("Unexpected output:\n%s", buf.String())
+	}
+
+	t.Setenv(Env, "off")
+	Begin(cfg, "ssh").Finish(0, nil)
+	if entries, _ := ReadFile(path, &Filter{}); len(entries) != 4 {
+		t.Errorf("Expected no entries written when history is off, got %d", len(entries))
+	}
+}
+
+func TestParseTime(t *testing.T) {
+	now := time.Date(2024, 3, 10, 12, 0, 0, 0, time.Local)
+	testCases := []struct {
+		input    string
+		expected time.Time
+	}{
+		{"24h", now.Add(-24 * time.Hour)},
+		{"7d", now.AddDate(0, 0, -7)},
+		{"2024-03-01", time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local)},
+		{"2024-03-01 08:30", time.Date(2024, 3, 1, 8, 30, 0, 0, time.Local)},
+		{"2024-03-01T08:30:00Z", time.Date(2024, 3, 1, 8, 30, 0, 0, time.UTC)},
+	}
+	for _, tc := range testCases {
+		got, err := ParseTime(tc.input, now)
+		if err != nil || !got.Equal(tc.expected) {
+			t.Errorf("ParseTime(%q): expected %v, got %v %v", tc.input, tc.expected, got, err)
+		}
+	}
+	for _, input := range []string{"", "yesterday", "7x", "d"} {
+		if _, err := ParseTime(input, now); err == nil {
+			t.Errorf("ParseTime(%q): expected error", input)
+		}
+	}
+}
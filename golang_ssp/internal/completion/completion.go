@@ -0,0 +1,289 @@
+package completion
+
+import (
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hostFields ssp add 和 ssp edit 的主机字段参数
+var hostFields = map[string]bool{
+	"hostname": true, "user": true, "port": true, "password": true, "password-stdin": false,
+	"i": true, "auth": true, "J": true, "tags": true, "record": true,
+}
+
+// Subcommands 子命令及其参数, true 表示参数需要值
+var Subcommands = map[string]map[string]bool{
+	"add":        hostFields,
+	"rm":         {"y": false},
+	"edit":       hostFields,
+	"show":       {},
+	"mv":         {},
+	"list":       {},
+	"exec":       {"j": true, "timeout": true},
+	"cp":         {"r": false, "p": false, "q": false},
+	"check":      {"j": true, "timeout": true},
+	"history":    {"since": true, "until": true, "failed": false, "n": true},
+	"replay":     {"speed": true, "idle": true},
+	"completion": {},
+}
+
+// Shells 支持生成补全脚本的 shell
+var Shells = []string{"bash", "zsh", "fish"}
+
+// Completer 根据已输入的参数给出补全候选
+// 候选每行一个, 可以用 \t 分隔附带说明; 没有候选时补全脚本回退到文件名补全
+type Completer struct {
+	Flags map[string]bool // 全局参数及是否需要值, 如 "del": true, "list": false
+	Hosts []config.SSHConfig
+}
+
+// Complete args 是命令名之后已输入的参数, 最后一个是正在输入的单词 (可以为空)
+func (c *Completer) Complete(args []string) []string {
+	if len(args) == 0 {
+		args = []string{""}
+	}
+	cur := args[len(args)-1]
+
+	flags := c.Flags
+	sub := ""
+	seen := map[string]bool{}
+	var positionals []string
+	pending := "" // 等待值的参数
+	afterDash := false
+	for _, w := range args[:len(args)-1] {
+		switch {
+		case pending != "":
+			pending = ""
+		case afterDash:
+			positionals = append(positionals, w)
+		case w == "--" && sub != "":
+			afterDash = true
+		case isFlag(w):
+			name, _, hasValue := strings.Cut(strings.TrimLeft(w, "-"), "=")
+			seen[name] = true
+			if flags[name] && !hasValue {
+				pending = name
+			}
+		case sub == "" && len(positionals) == 0 && Subcommands[w] != nil:
+			sub, flags = w, Subcommands[w]
+		default:
+			positionals = append(positionals, w)
+		}
+	}
+
+	var candidates []string
+	switch {
+	case pending != "":
+		candidates = c.flagValues(sub, pending, cur)
+	case afterDash:
+		return nil
+	case strings.HasPrefix(cur, "-"):
+		for name := range flags {
+			candidates = append(candidates, "-"+name)
+		}
+		sort.Strings(candidates)
+	case sub == "":
+		candidates = c.topLevel(cur, seen, positionals)
+	default:
+		candidates = c.subcommand(sub, cur, positionals)
+	}
+	return filter(candidates, cur)
+}
+
+func subcommandNames() []string {
+	var names []string
+	for name := range Subcommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func isFlag(w string) bool {
+	return len(w) > 1 && w[0] == '-' && w != "--"
+}
+
+func (c *Completer) topLevel(cur string, seen map[string]bool, positionals []string) []string {
+	switch {
+	case seen["tag"] || seen["untag"]:
+		return c.targets(cur)
+	case seen["list"]:
+		if len(positionals) == 0 {
+			return commaList(cur, c.tags())
+		}
+		return nil
+	case len(positionals) > 0:
+		return nil
+	}
+
+	candidates := c.targets(cur)
+	candidates = append(candidates, c.userHosts()...)
+	candidates = append(candidates, c.indexes(cur)...)
+	return append(candidates, subcommandNames()...)
+}
+
+func (c *Completer) subcommand(sub, cur string, positionals []string) []string {
+	switch sub {
+	case "rm":
+		return append(c.targets(cur), c.indexes(cur)...)
+	case "edit", "show":
+		if len(positionals) == 0 {
+			return append(c.hosts(), c.indexes(cur)...)
+		}
+	case "mv":
+		if len(positionals) == 0 {
+			return c.hosts()
+		}
+	case "list":
+		if len(positionals) == 0 {
+			return commaList(cur, c.tags())
+		}
+	case "completion":
+		if len(positionals) == 0 {
+			return Shells
+		}
+	case "exec", "check":
+		return c.targets(cur)
+	case "history":
+		if len(positionals) == 0 {
+			return c.hosts()
+		}
+	case "cp":
+		// 远程路径无法补全, 本地路径交给 shell 的文件名补全
+		if strings.Contains(cur, ":") || strings.HasPrefix(cur, ".") || strings.HasPrefix(cur, "/") || strings.HasPrefix(cur, "~") {
+			return nil
+		}
+		var candidates []string
+		for _, h := range c.Hosts {
+			candidates = append(candidates, h.Host+":\t"+h.User+"@"+h.Hostname)
+		}
+		for _, uh := range c.userHosts() {
+			candidates = append(candidates, uh+":")
+		}
+		return candidates
+	}
+	return nil
+}
+
+// flagValues 参数值的候选, 返回空时补全文件名
+func (c *Completer) flagValues(sub, name, cur string) []string {
+	if sub != "" && sub != "add" && sub != "edit" {
+		return nil
+	}
+	switch name {
+	case "backend":
+		return []string{"native", "sshpass"}
+	case "record":
+		return []string{"on", "off"}
+	case "auth":
+		return commaList(cur, strings.Split(config.DefaultAuthMethods, ","))
+	case "tag", "untag", "tags":
+		return commaList(cur, c.tags())
+	case "host", "J", "tunnel":
+		return c.hosts()
+	case "hostname":
+		var names []string
+		for _, h := range c.Hosts {
+			names = append(names, h.Hostname)
+		}
+		return names
+	case "del":
+		return append(c.targets(cur), c.indexes(cur)...)
+	case "accept-key":
+		return c.targets(cur)
+	}
+	return nil
+}
+
+// hosts Host 名称, 说明为 user@hostname
+func (c *Completer) hosts() []string {
+	var candidates []string
+	for _, h := range c.Hosts {
+		candidates = append(candidates, h.Host+"\t"+h.User+"@"+h.Hostname)
+	}
+	return candidates
+}
+
+// targets 可以用主机或 @group 选择的位置, 输入 @ 时才给出分组
+func (c *Completer) targets(cur string) []string {
+	if !strings.HasPrefix(cur, "@") {
+		return c.hosts()
+	}
+	groups := []string{"@*\tall hosts"}
+	head := cur[:strings.LastIndex(cur, ",")+1]
+	if head == "" {
+		head = "@"
+	}
+	for _, tag := range c.tags() {
+		groups = append(groups, head+tag)
+	}
+	return groups
+}
+
+func (c *Completer) userHosts() []string {
+	var candidates []string
+	seen := map[string]bool{}
+	for _, h := range c.Hosts {
+		uh := h.User + "@" + h.Hostname
+		if !seen[uh] {
+			seen[uh] = true
+			candidates = append(candidates, uh)
+		}
+	}
+	return candidates
+}
+
+// indexes -list 的序号, 只在输入数字时给出
+func (c *Completer) indexes(cur string) []string {
+	if _, err := strconv.Atoi(cur); err != nil {
+		return nil
+	}
+	var candidates []string
+	for i, h := range c.Hosts {
+		candidates = append(candidates, fmt.Sprintf("%d\t%s", i, h.Host))
+	}
+	return candidates
+}
+
+// tags 缓存中出现过的标签, 去重排序
+func (c *Completer) tags() []string {
+	seen := map[string]bool{}
+	var tags []string
+	for _, h := range c.Hosts {
+		for _, tag := range h.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// commaList 逗号分隔的列表只补全最后一项
+func commaList(cur string, items []string) []string {
+	head := cur[:strings.LastIndex(cur, ",")+1]
+	var candidates []string
+	for _, item := range items {
+		candidates = append(candidates, head+item)
+	}
+	return candidates
+}
+
+// filter 保留以 cur 开头的候选, 说明部分不参与匹配
+func filter(candidates []string, cur string) []string {
+	var result []string
+	seen := map[string]bool{}
+	for _, c := range candidates {
+		value, _, _ := strings.Cut(c, "\t")
+		if strings.HasPrefix(value, cur) && !seen[value] {
+			seen[value] = true
+			result = append(result, c)
+		}
+	}
+	return result
+}
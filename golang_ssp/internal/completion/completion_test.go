@@ -0,0 +1,82 @@
+package completion
+
+import (
+	"golang_ssp/golang_ssp/internal/config"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestComplete(t *testing.T) {
+	c := &Completer{
+		Flags: map[string]bool{"list": false, "del": true, "backend": true, "auth": true, "tag": true, "sftp": false},
+		Hosts: []config.SSHConfig{
+			{Host: "node1", Hostname: "10.0.0.1", User: "root", Tags: []string{"env=prod", "role=db"}},
+			{Host: "node2", Hostname: "10.0.0.2", User: "root", Tags: []string{"env=dev"}},
+			{Host: "web1", Hostname: "10.0.0.3", User: "admin"},
+		},
+	}
+
+	testCases := []struct {
+		args     []string
+		expected []string
+	}{
+		{[]string{"no"}, []string{"node1", "node2"}},
+		{[]string{"ro"}, []string{"root@10.0.0.1", "root@10.0.0.2"}},
+		{[]string{"ch"}, []string{"check"}},
+		{[]string{"1"}, []string{"1"}},
+		{[]string{"@env=p"}, []string{"@env=prod"}},
+		{[]string{"@env=prod,r"}, []string{"@env=prod,role=db"}},
+		{[]string{"-sftp", "w"}, []string{"web1"}},
+		{[]string{"-de"}, []string{"-del"}},
+		{[]string{"-del", "@"}, []string{"@*", "@env=dev", "@env=prod", "@role=db"}},
+		{[]string{"-backend", ""}, []string{"native", "sshpass"}},
+		{[]string{"-auth", "agent,p"}, []string{"agent,password"}},
+		{[]string{"-list", "env"}, []string{"env=dev", "env=prod"}},
+		{[]string{"-tag", "env=prod", "node1", "w"}, []string{"web1"}},
+		{[]string{"node1", ""}, nil},
+		{[]string{"exec", "-"}, []string{"-j", "-timeout"}},
+		{[]string{"exec", "-j", "2", "@role"}, []string{"@role=db"}},
+		{[]string{"exec", "@*", "--", "u"}, nil},
+		{[]string{"check", "w"}, []string{"web1"}},
+		{[]string{"history", "-since", "7d", "n"}, []string{"node1", "node2"}},
+		{[]string{"cp", "-r", "w"}, []string{"web1:"}},
+		{[]string{"cp", "admin@"}, []string{"admin@10.0.0.3:"}},
+		{[]string{"cp", "./"}, nil},
+		{[]string{"cp", "node1:/tmp"}, nil},
+		{[]string{"replay", ""}, nil},
+		{[]string{"completion", "z"}, []string{"zsh"}},
+		{[]string{"rm", "node1", "@env=d"}, []string{"@env=dev"}},
+		{[]string{"edit", "-port", "2222", "w"}, []string{"web1"}},
+		{[]string{"edit", "-record", ""}, []string{"on", "off"}},
+		{[]string{"add", "-J", "n"}, []string{"node1", "node2"}},
+		{[]string{"mv", "node1", ""}, nil},
+		{[]string{"list", "role"}, []string{"role=db"}},
+	}
+	for _, tc := range testCases {
+		var got []string
+		for _, candidate := range c.Complete(tc.args) {
+			value, _, _ := strings.Cut(candidate, "\t")
+			got = append(got, value)
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("Complete(%q): expected %q, got %q", tc.args, tc.expected, got)
+		}
+	}
+
+	if candidates := c.Complete([]string{"web"}); len(candidates) != 1 || candidates[0] != "web1\tadmin@10.0.0.3" {
+		t.Errorf("Expected host with description, got %q", candidates)
+	}
+}
+
+func TestScript(t *testing.T) {
+	for _, shell := range Shells {
+		script, err := Script(shell)
+		if err != nil || !strings.Contains(script, "__complete") {
+			t.Errorf("Unexpected %s script: %v", shell, err)
+		}
+	}
+	if _, err := Script("tcsh"); err == nil {
+		t.Errorf("Expected error for unsupported shell")
+	}
+}
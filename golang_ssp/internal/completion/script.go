@@ -0,0 +1,95 @@
+package completion
+
+import "fmt"
+
+// Script 返回 shell 的补全脚本, 候选在补全时通过 ssp __complete 从缓存中读取
+func Script(shell string) (string, error) {
+	switch shell {
+	case "bash":
+		return bashScript, nil
+	case "zsh":
+		return zshScript, nil
+	case "fish":
+		return fishScript, nil
+	}
+	return "", fmt.Errorf("unsupported shell %q, expected bash, zsh or fish", shell)
+}
+
+const bashScript = `# bash completion for ssp and ssftp
+# source <(ssp completion bash), or save to /etc/bash_completion.d/ssp
+_ssp_complete() {
+    local line="${COMP_LINE:0:COMP_POINT}"
+    local -a words
+    read -ra words <<< "$line"
+    [[ "$line" =~ [[:space:]]$ ]] && words+=("")
+    local cur="${words[${#words[@]}-1]}"
+    # bash 按 COMP_WORDBREAKS 中的 : 和 = 切分单词, 候选需要去掉当前单词中最后一个 : 或 = 之前的部分
+    local prefix="${cur%"${cur##*[:=]}"}"
+
+    local IFS=$'\n'
+    local -a candidates
+    candidates=($("${words[0]}" __complete "${words[@]:1}" 2>/dev/null))
+    COMPREPLY=()
+    local c
+    for c in "${candidates[@]}"; do
+        c="${c%%$'\t'*}"
+        COMPREPLY+=("${c#"$prefix"}")
+    done
+    if [[ ${#COMPREPLY[@]} -eq 1 && "${candidates[0]%%$'\t'*}" == *: ]]; then
+        compopt -o nospace 2>/dev/null
+    fi
+}
+complete -o default -F _ssp_complete ssp ssftp
+`
+
+const zshScript = `#compdef ssp ssftp
+# zsh completion for ssp and ssftp
+# source <(ssp completion zsh), or save as _ssp in a directory of $fpath
+_ssp() {
+    local -a lines candidates remotes
+    lines=("${(@f)$(${words[1]} __complete "${(@)words[2,CURRENT]}" 2>/dev/null)}")
+    local line value
+    for line in $lines; do
+        [[ -z "$line" ]] && continue
+        value="${line%%$'\t'*}"
+        if [[ "$line" == *$'\t'* ]]; then
+            value="${value//:/\\:}:${line#*$'\t'}"
+        else
+            value="${value//:/\\:}"
+        fi
+        if [[ "$line" == *:$'\t'* || "$line" == *: ]]; then
+            remotes+=("$value")
+        else
+            candidates+=("$value")
+        fi
+    done
+    if (( ${#candidates} + ${#remotes} == 0 )); then
+        _files
+        return
+    fi
+    _describe -t values 'ssp' candidates -- remotes -S ''
+}
+if [[ "$funcstack[1]" == "_ssp" ]]; then
+    _ssp "$@"
+else
+    compdef _ssp ssp ssftp
+fi
+`
+
+const fishScript = `# fish completion for ssp and ssftp
+# ssp completion fish | source, or save to ~/.config/fish/completions/ssp.fish
+function __ssp_complete
+    set -l tokens (commandline -opc)
+    set -l cmd $tokens[1]
+    set -e tokens[1]
+    set -l cur (commandline -ct)
+    set -l result ($cmd __complete $tokens "$cur" 2>/dev/null)
+    if test (count $result) -eq 0
+        __fish_complete_path "$cur"
+        return
+    end
+    printf '%s\n' $result
+end
+complete -c ssp -f -a '(__ssp_complete)'
+complete -c ssftp -f -a '(__ssp_complete)'
+`
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"golang_ssp/golang_ssp/pkg/logger"
+	"net"
 	"os"
 	"path/filepath"
 	"sort"
@@ -16,46 +17,164 @@ type SSHConfig struct {
 	Host          string
 	Hostname      string
 	User          string
-	Port          string
-	Password      string // Not recommended to store passwords in plain text
-	LoginTimes    string
-	LastLoginTime string // 2022-01-01T15:04:05
+	Port          uint16 // 0 表示默认端口 22
+	Password      string // Not recommended to store passwords in plain text, use ssp -encrypt
+	LoginTimes    int
+	LastLoginTime time.Time  // UTC, 零值表示从未登录
+	HostKey       string     // 服务器主机密钥指纹, SHA256:xxx, 首次登录成功时记录
+	IdentityFile  string     // 私钥路径, 如 ~/.ssh/id_ed25519
+	AuthMethods   string     // 认证方式及顺序, 如 agent,key,password,keyboard-interactive
+	ProxyJump     string     // 跳板机, 引用缓存中另一个条目的 Host
+	Forwards      []Forward  // 保存的端口转发
+	Tags          []string   // 标签, 如 env=prod、role=db, 用于分组
+	Record        bool       // 录制交互式登录, 保存为 asciicast 文件
+	LastCheck     CheckState // 最近一次 ssp check 的结果
 }
 
+// CheckState ssp check 的结果, 保存为 #ssp:LastCheck <时间> <状态> <延迟>
+type CheckState struct {
+	Time    time.Time // UTC, 零值表示从未检查
+	Status  string    // ok, unreachable, bad-banner, auth-failed, hostkey-mismatch, timeout, error
+	Latency time.Duration
+}
+
+func (c CheckState) String() string {
+	return fmt.Sprintf("%s %s %s", c.Time.UTC().Format(time.RFC3339), c.Status, c.Latency)
+}
+
+// ParseCheckState 解析 #ssp:LastCheck 的值
+func ParseCheckState(value string) (CheckState, error) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 {
+		return CheckState{}, fmt.Errorf("invalid LastCheck %q, expected <time> <status> <latency>", value)
+	}
+	t, err := time.Parse(time.RFC3339, fields[0])
+	if err != nil {
+		return CheckState{}, fmt.Errorf("invalid LastCheck time %q", fields[0])
+	}
+	latency, err := time.ParseDuration(fields[2])
+	if err != nil || latency < 0 {
+		return CheckState{}, fmt.Errorf("invalid LastCheck latency %q", fields[2])
+	}
+	return CheckState{Time: t.UTC(), Status: fields[1], Latency: latency}, nil
+}
+
+// TIMEFORMAT 旧版本缓存中 LastLoginTime 的格式, 不带时区, 按本地时间解析
 const TIMEFORMAT = "2006-01-02T15:04:05"
 
-func (s *SSHConfig) Equals(s2 *SSHConfig) bool {
-	return s.Host == s2.Host && s.Hostname == s2.Hostname && s.User == s2.User && s.Port == s2.Port && s.Password == s2.Password && s.LoginTimes == s2.LoginTimes && s.LastLoginTime == s2.LastLoginTime
+const DefaultPort = 22
+
+// ParsePort 解析端口号, 为空时返回默认端口
+func ParsePort(port string) (uint16, error) {
+	port = strings.TrimSpace(port)
+	if port == "" {
+		return DefaultPort, nil
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil || p == 0 {
+		return 0, fmt.Errorf("invalid port %q, expected 1-65535", port)
+	}
+	return uint16(p), nil
 }
 
-func (s *SSHConfig) Compare(s2 *SSHConfig) bool {
-	// high than s2 ,return true
+// ParseLoginTime 解析 RFC 3339 格式的时间, 兼容旧版本不带时区的本地时间, 统一转换为 UTC
+func ParseLoginTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UTC(), nil
+	}
+	t, err := time.ParseInLocation(TIMEFORMAT, value, time.Local)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected RFC 3339 like 2023-07-01T12:00:00Z", value)
+	}
+	return t.UTC(), nil
+}
 
-	// 谁最新谁优先
-	if s.LastLoginTime != "" && s2.LastLoginTime != "" && s.LastLoginTime != s2.LastLoginTime {
+// Address 返回 host:port 形式的地址
+func (s *SSHConfig) Address() string {
+	return net.JoinHostPort(s.Hostname, strconv.Itoa(int(s.PortOrDefault())))
+}
 
-		aTime, err1 := time.Parse(TIMEFORMAT, s.LastLoginTime)
-		bTime, err2 := time.Parse(TIMEFORMAT, s2.LastLoginTime)
-		if err1 != nil && err2 == nil {
-			return false
-		}
-		if err2 != nil && err1 == nil {
-			return true
-		}
+// Key 条目的唯一标识, 同一个 Host 或 HostName 可以有多个账号, 由 Host、User、HostName 和 Port 区分
+func (s *SSHConfig) Key() string {
+	return s.Host + " " + s.User + "@" + s.Address()
+}
 
-		return aTime.After(bTime)
+func (s *SSHConfig) PortOrDefault() uint16 {
+	if s.Port == 0 {
+		return DefaultPort
 	}
+	return s.Port
+}
 
-	// 谁登陆次数多谁优先
-	if s.LoginTimes != s2.LastLoginTime {
-		ai, _ := strconv.Atoi(s.LoginTimes)
-		bi, _ := strconv.Atoi(s2.LoginTimes)
+// 支持的认证方式
+const (
+	AuthAgent               = "agent"
+	AuthKey                 = "key"
+	AuthPassword            = "password"
+	AuthKeyboardInteractive = "keyboard-interactive"
 
-		return bi < ai
+	DefaultAuthMethods = "agent,key,password,keyboard-interactive"
+)
 
+// ParseAuthMethods 解析逗号分隔的认证方式, 为空时使用默认顺序
+func ParseAuthMethods(methods string) ([]string, error) {
+	if strings.TrimSpace(methods) == "" {
+		methods = DefaultAuthMethods
 	}
+	var result []string
+	for _, method := range strings.Split(methods, ",") {
+		method = strings.TrimSpace(method)
+		switch method {
+		case AuthAgent, AuthKey, AuthPassword, AuthKeyboardInteractive:
+			result = append(result, method)
+		default:
+			return nil, fmt.Errorf("unknown auth method %q, expected one of %s", method, DefaultAuthMethods)
+		}
+	}
+	return result, nil
+}
 
-	return strings.Compare(s2.Host, s.Host) < 0
+func (s *SSHConfig) Equals(s2 *SSHConfig) bool {
+	return s.Host == s2.Host && s.Hostname == s2.Hostname && s.User == s2.User && s.Port == s2.Port && s.Password == s2.Password && s.LoginTimes == s2.LoginTimes && s.LastLoginTime.Equal(s2.LastLoginTime) && s.HostKey == s2.HostKey &&
+		s.IdentityFile == s2.IdentityFile && s.AuthMethods == s2.AuthMethods && s.ProxyJump == s2.ProxyJump &&
+		forwardsEqual(s.Forwards, s2.Forwards) && tagsEqual(s.Tags, s2.Tags) && s.Record == s2.Record &&
+		s.LastCheck.Time.Equal(s2.LastCheck.Time) && s.LastCheck.Status == s2.LastCheck.Status && s.LastCheck.Latency == s2.LastCheck.Latency
+}
+
+// Frecency 综合登录次数和最近登录时间的得分, 越近期登录的次数权重越高
+func (s *SSHConfig) Frecency(now time.Time) float64 {
+	if s.LoginTimes <= 0 || s.LastLoginTime.IsZero() {
+		return 0
+	}
+	age := now.Sub(s.LastLoginTime)
+	weight := 0.25
+	switch {
+	case age < time.Hour:
+		weight = 4
+	case age < 24*time.Hour:
+		weight = 2
+	case age < 7*24*time.Hour:
+		weight = 0.5
+	}
+	return float64(s.LoginTimes) * weight
+}
+
+// Compare 排序时 s 是否排在 s2 之前: 按 frecency 得分, 相同时最近登录的优先, 再按登录次数和 Host 名称
+func (s *SSHConfig) Compare(s2 *SSHConfig) bool {
+	return s.compareAt(s2, time.Now())
+}
+
+func (s *SSHConfig) compareAt(s2 *SSHConfig, now time.Time) bool {
+	if a, b := s.Frecency(now), s2.Frecency(now); a != b {
+		return a > b
+	}
+	if !s.LastLoginTime.Equal(s2.LastLoginTime) {
+		return s.LastLoginTime.After(s2.LastLoginTime)
+	}
+	if s.LoginTimes != s2.LoginTimes {
+		return s.LoginTimes > s2.LoginTimes
+	}
+	return s.Host < s2.Host
 }
 
 func (s *SSHConfig) Update(s2 *SSHConfig) {
@@ -66,35 +185,52 @@ func (s *SSHConfig) Update(s2 *SSHConfig) {
 	s.Port = s2.Port
 	s.LastLoginTime = s2.LastLoginTime
 	s.LoginTimes = s2.LoginTimes
+	s.HostKey = s2.HostKey
+	s.IdentityFile = s2.IdentityFile
+	s.AuthMethods = s2.AuthMethods
+	s.ProxyJump = s2.ProxyJump
+	s.Forwards = s2.Forwards
+	s.Tags = s2.Tags
+	s.Record = s2.Record
+	s.LastCheck = s2.LastCheck
 }
 func (s *SSHConfig) String() string {
-	if s.LoginTimes == "" {
-		s.LoginTimes = "0"
+	str := fmt.Sprintf("Host %s\n  HostName %s\n  User %s\n  Port %d\n", s.Host, s.Hostname, s.User, s.PortOrDefault())
+	if s.IdentityFile != "" {
+		str += fmt.Sprintf("  IdentityFile %s\n", s.IdentityFile)
 	}
-
-	if s.LastLoginTime == "" {
-		s.LastLoginTime = "1977-01-01T15:04:05"
+	if s.ProxyJump != "" {
+		str += fmt.Sprintf("  ProxyJump %s\n", s.ProxyJump)
 	}
-
-	if s.Port == "" {
-		s.Port = "22"
+	for _, f := range s.Forwards {
+		str += fmt.Sprintf("  %s\n", f)
 	}
-
-	return fmt.Sprintf("Host %s\n  HostName %s\n  User %s\n  Port %s\n  #Password %s\n  #LoginTimes %s\n  #LastLoginTime %s\n", s.Host, s.Hostname, s.User, s.Port, s.Password, s.LoginTimes, s.LastLoginTime)
+	str += fmt.Sprintf("  %sPassword %s\n  %sLoginTimes %d\n", metaPrefix, s.Password, metaPrefix, s.LoginTimes)
+	if !s.LastLoginTime.IsZero() {
+		str += fmt.Sprintf("  %sLastLoginTime %s\n", metaPrefix, s.LastLoginTime.UTC().Format(time.RFC3339))
+	}
+	if s.AuthMethods != "" {
+		str += fmt.Sprintf("  %sAuthMethods %s\n", metaPrefix, s.AuthMethods)
+	}
+	if len(s.Tags) > 0 {
+		str += fmt.Sprintf("  %sTags %s\n", metaPrefix, strings.Join(s.Tags, ","))
+	}
+	if s.Record {
+		str += fmt.Sprintf("  %sRecord yes\n", metaPrefix)
+	}
+	if !s.LastCheck.Time.IsZero() {
+		str += fmt.Sprintf("  %sLastCheck %s\n", metaPrefix, s.LastCheck)
+	}
+	if s.HostKey != "" {
+		str += fmt.Sprintf("  %sHostKey %s\n", metaPrefix, s.HostKey)
+	}
+	return str
 }
 
+// Increase 增加登录次数并更新上次登录时间
 func (s *SSHConfig) Increase() {
-	// 增加登录次数
-	if s.LoginTimes == "" {
-		s.LoginTimes = "0"
-	}
-	times, _ := strconv.Atoi(s.LoginTimes)
-
-	s.LoginTimes = strconv.Itoa(times + 1)
-
-	// 更新上次登录时间
-	s.LastLoginTime = time.Now().Format("2006-01-02T15:04:05")
-
+	s.LoginTimes++
+	s.LastLoginTime = time.Now().UTC().Truncate(time.Second)
 }
 
 // ReadConfig 读取SSH配置文件并解析成SSHConfig结构体的切片。
@@ -102,81 +238,236 @@ func (s *SSHConfig) Increase() {
 // 返回值是解析后的SSH配置切片和可能出现的错误。
 func ReadConfig(configPath string) (*[]SSHConfig, error) {
 
-	configPath = absPath(configPath)
+	configPath = AbsPath(configPath)
 
 	if _, err := os.Stat(configPath); err != nil {
 
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		logger.Logger.Printf("SSH config file not found, try to create %s\n", configPath)
+		file, err := os.OpenFile(configPath, os.O_WRONLY|os.O_CREATE, 0600)
+		if err != nil {
+			logger.Logger.Printf("Create SSH config file failed, %s\n", err)
+			return nil, err
+		}
+		file.Close()
+	}
+
+	configs, err := readConfigFile(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// 解密密码
+	if err := decryptConfigs(configs); err != nil {
+		return nil, err
+	}
+	SortConfigs(&configs)
+
+	return &configs, nil
+}
+
+// ReadHosts 只读取主机列表, 不创建缓存文件也不解密密码, 用于补全等不需要认证信息的场景
+func ReadHosts(configPath string) ([]SSHConfig, error) {
+	configs, err := readConfigFile(AbsPath(configPath))
+	if err != nil {
 		if os.IsNotExist(err) {
-			logger.Logger.Printf("SSH config file not found, try to create %s\n", configPath)
-			if _, err = os.Create(configPath); err != nil {
-				logger.Logger.Printf("Create SSH config file failed, %s\n", err)
-				return nil, err
-			}
+			return nil, nil
 		}
 		return nil, err
 	}
+	SortConfigs(&configs)
+	return configs, nil
+}
 
-	file, err := os.Open(configPath)
+func readConfigFile(configPath string) ([]SSHConfig, error) {
+	content, err := os.ReadFile(configPath)
 	if err != nil {
 		return nil, err
 	}
-	defer file.Close()
 
+	// 旧版本的缓存在内存中迁移, 写回时使用当前格式
+	lines := strings.Split(string(content), "\n")
+	version, start := detectVersion(lines)
+	vault = start > 0 && start < len(lines) && strings.TrimSpace(lines[start]) == vaultMarker
+	if vault {
+		start++
+	}
+	lines, err = migrateLines(configPath, lines[start:], version)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfigLines(configPath, lines, start)
+}
+
+// LineError 缓存文件中某一行的错误
+type LineError struct {
+	Path string
+	Line int
+	Err  error
+}
+
+func (e *LineError) Error() string {
+	return fmt.Sprintf("%s:%d: %v", e.Path, e.Line, e.Err)
+}
+
+func (e *LineError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError 读取缓存时发现的所有错误
+type ValidationError []*LineError
+
+func (e ValidationError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// parseConfigLines 解析当前版本的缓存内容, offset 为正文之前的行数, 用于错误中的行号
+// ssp 专用字段以 #ssp: 开头, 其余注释忽略
+func parseConfigLines(configPath string, lines []string, offset int) ([]SSHConfig, error) {
 	var configs []SSHConfig
-	var currentConfig SSHConfig = SSHConfig{Password: "", LoginTimes: "0", LastLoginTime: ""}
+	var errs ValidationError
+	var current *SSHConfig
+	hostLine := 0
+
+	fail := func(line int, format string, a ...any) {
+		errs = append(errs, &LineError{Path: configPath, Line: line, Err: fmt.Errorf(format, a...)})
+	}
+	finish := func() {
+		if current == nil {
+			return
+		}
+		if current.Hostname == "" {
+			fail(hostLine, "host %s has no HostName", current.Host)
+		}
+		configs = append(configs, *current)
+	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for i, line := range lines {
+		lineNo := offset + i + 1
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
 
-		if strings.HasPrefix(line, "#") &&
-			!strings.Contains(line, "Password") &&
-			!strings.Contains(line, "LoginTimes") &&
-			!strings.Contains(line, "LastLoginTime") {
+		if strings.HasPrefix(line, metaPrefix) {
+			line = strings.TrimPrefix(line, metaPrefix)
+		} else if strings.HasPrefix(line, "#") {
 			continue
 		}
 
-		line = strings.TrimSpace(strings.TrimPrefix(line, "#"))
-
 		parts := strings.SplitN(line, " ", 2)
-		if len(parts) != 2 {
+		key, value := parts[0], ""
+		if len(parts) == 2 {
+			value = strings.TrimSpace(parts[1])
+		}
+
+		if key == "Host" {
+			finish()
+			if value == "" {
+				fail(lineNo, "Host without name")
+			}
+			current = &SSHConfig{Host: value, Port: DefaultPort}
+			hostLine = lineNo
+			continue
+		}
+		if current == nil {
+			fail(lineNo, "%s outside of a Host entry", key)
 			continue
 		}
 
-		key, value := parts[0], parts[1]
 		switch key {
-		case "Host":
-			if currentConfig.Host != "" {
-				configs = append(configs, currentConfig)
-			}
-			currentConfig = SSHConfig{Host: value, Port: "22", Password: "", LoginTimes: "0", LastLoginTime: ""}
 		case "HostName":
-			currentConfig.Hostname = value
+			current.Hostname = value
 		case "User":
-			currentConfig.User = value
+			current.User = value
 		case "Port":
-			currentConfig.Port = value
+			port, err := ParsePort(value)
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			current.Port = port
 		case "Password":
-			currentConfig.Password = value
+			current.Password = value
 		case "LastLoginTime":
-			currentConfig.LastLoginTime = value
+			if value == "" {
+				continue
+			}
+			t, err := ParseLoginTime(value)
+			if err != nil {
+				fail(lineNo, "invalid LastLoginTime: %v", err)
+				continue
+			}
+			current.LastLoginTime = t
 		case "LoginTimes":
-			currentConfig.LoginTimes = value
+			times, err := strconv.Atoi(value)
+			if err != nil || times < 0 {
+				fail(lineNo, "invalid LoginTimes %q, expected a non-negative integer", value)
+				continue
+			}
+			current.LoginTimes = times
+		case "HostKey":
+			current.HostKey = value
+		case "IdentityFile":
+			current.IdentityFile = value
+		case "AuthMethods":
+			if _, err := ParseAuthMethods(value); err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			current.AuthMethods = value
+		case "ProxyJump":
+			current.ProxyJump = value
+		case "Tags":
+			tags, err := ParseTags(value)
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			current.Tags = tags
+		case "Record":
+			switch strings.ToLower(value) {
+			case "yes":
+				current.Record = true
+			case "no":
+				current.Record = false
+			default:
+				fail(lineNo, "invalid Record %q, expected yes or no", value)
+			}
+		case "LastCheck":
+			state, err := ParseCheckState(value)
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			current.LastCheck = state
+		case "LocalForward", "RemoteForward", "DynamicForward":
+			f, err := parseForwardLine(key, value)
+			if err != nil {
+				fail(lineNo, "%v", err)
+				continue
+			}
+			current.Forwards = append(current.Forwards, f)
+		default:
+			logger.Logger.Printf("%s:%d: unknown key %s, it will be dropped on next write\n", configPath, lineNo, key)
 		}
 	}
+	finish()
 
-	if currentConfig.Host != "" {
-		configs = append(configs, currentConfig)
+	if len(errs) > 0 {
+		return nil, errs
 	}
-	SortConfigs(&configs)
-
-	return &configs, scanner.Err()
+	return configs, nil
 }
 
-func absPath(path string) string {
+// AbsPath 展开 ~ 并返回绝对路径
+func AbsPath(path string) string {
 	if strings.HasPrefix(path, "~") {
 		homeDir, err := os.UserHomeDir()
 		if err != nil {
@@ -196,47 +487,138 @@ func SortConfigs(configs *[]SSHConfig) {
 	})
 }
 
+// WriteConfig 以当前格式版本写入缓存文件
+// 需要读-改-写时使用 UpdateConfig
 func WriteConfig(configPath string, configs []SSHConfig) error {
-	configPath = absPath(configPath)
-	file, err := os.Create(configPath)
+	return writeFileAtomic(AbsPath(configPath), func(file *os.File) error {
+		return writeConfigs(file, configs)
+	})
+}
+
+// writeFileAtomic 先写入同目录下的临时文件, fsync 后再 rename 覆盖, 避免写到一半时留下不完整的缓存文件
+func writeFileAtomic(path string, write func(file *os.File) error) error {
+	dir := filepath.Dir(path)
+	file, err := os.CreateTemp(dir, "."+filepath.Base(path)+"-*")
 	if err != nil {
 		return err
 	}
+	defer os.Remove(file.Name())
 	defer file.Close()
 
+	if err := write(file); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), path); err != nil {
+		return err
+	}
+
+	// rename 本身也需要落盘
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+	return nil
+}
+
+func writeConfigs(file *os.File, configs []SSHConfig) error {
 	writer := bufio.NewWriter(file)
+	if _, err := fmt.Fprintf(writer, versionHeader+"\n", CurrentVersion); err != nil {
+		return err
+	}
+	if VaultEnabled() {
+		if _, err := writer.WriteString(vaultMarker + "\n"); err != nil {
+			return err
+		}
+	}
+	if err := writeEntries(writer, configs); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
+
+func writeEntries(writer *bufio.Writer, configs []SSHConfig) error {
 	for _, config := range configs {
+		// 开启加密存储时只写入密文
+		if VaultEnabled() && config.Password != "" && !IsEncrypted(config.Password) {
+			if err := ensureWritePassphrase(configs); err != nil {
+				return err
+			}
+			password, err := EncryptPassword(config.Password)
+			if err != nil {
+				return err
+			}
+			config.Password = password
+		}
 		_, err := writer.WriteString(config.String())
 		if err != nil {
 			return err
 		}
 	}
-	return writer.Flush()
+	return nil
 }
 
 func ListConfigs(configs []SSHConfig) {
+	ListSelected(configs, &Selector{all: true})
+}
+
+// ListSelected 列出所有选中的主机, 序号仍然是在全部主机中的位置, 可以用于 ssp <index>
+func ListSelected(configs []SSHConfig, sel *Selector) {
 	if len(configs) == 0 {
 		logger.Logger.Println("No configurations found")
 		return
 	}
 	for i, config := range configs {
-		fmt.Printf("%-4d%-25s%-15s%-15s%-15s%-15s%-15s\n", i, config.Host, config.Hostname, config.User, config.Port, config.LoginTimes, config.LastLoginTime)
-		if i > 20 {
-			break
+		if !sel.Match(&config) {
+			continue
 		}
+		lastLogin := "-"
+		if !config.LastLoginTime.IsZero() {
+			lastLogin = config.LastLoginTime.Local().Format("2006-01-02 15:04")
+		}
+		fmt.Printf("%-4d%-25s%-15s%-15s%-15d%-15d%-18s%s\n", i, config.Host, config.Hostname, config.User, config.PortOrDefault(), config.LoginTimes, lastLogin, strings.Join(config.Tags, ","))
 	}
 }
 
+// GetSSHConfig 返回第一个匹配的账号, 匹配规则见 FindSSHConfigs
 func GetSSHConfig(c *[]SSHConfig, t *SSHConfig) (*SSHConfig, error) {
-	for _, config := range *c {
-		if t.Host != "" && config.Host == t.Host {
-			return &config, nil
-		}
+	matches := FindSSHConfigs(*c, t)
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no config found for host %v", t)
+	}
+	return &matches[0], nil
+}
+
+// FindSSHConfigs 返回匹配 t 的所有账号, 依次按 Host、HostName 查找, t.Hostname 也可以是别名 (如 user@node1)
+// t 中指定了 User 或 Port 时只返回相同的账号
+func FindSSHConfigs(c []SSHConfig, t *SSHConfig) []SSHConfig {
+	fields := []struct {
+		value string
+		field func(*SSHConfig) string
+	}{
+		{t.Host, func(s *SSHConfig) string { return s.Host }},
+		{t.Hostname, func(s *SSHConfig) string { return s.Hostname }},
+		{t.Hostname, func(s *SSHConfig) string { return s.Host }},
 	}
-	for _, config := range *c {
-		if t.Hostname != "" && config.Hostname == t.Hostname {
-			return &config, nil
+	for _, f := range fields {
+		if f.value == "" {
+			continue
+		}
+		var matches []SSHConfig
+		for _, config := range c {
+			if f.field(&config) != f.value || (t.User != "" && config.User != t.User) || (t.Port != 0 && config.PortOrDefault() != t.Port) {
+				continue
+			}
+			matches = append(matches, config)
+		}
+		if len(matches) > 0 {
+			return matches
 		}
 	}
-	return nil, fmt.Errorf("no config found for host %v", t)
+	return nil
 }
@@ -1,10 +1,19 @@
 package config
 
 import (
+	"errors"
 	"os"
+	"strings"
 	"testing"
+	"time"
 )
 
+// loginTime 解析测试用的时间, 不带时区时按本地时间
+func loginTime(value string) time.Time {
+	t, _ := ParseLoginTime(value)
+	return t
+}
+
 func TestReadConfig(t *testing.T) {
 
 	// 创建临时目录
@@ -55,10 +64,10 @@ Host test4
 	}
 	// 验证结果
 	expectedConfigs := []SSHConfig{
-		{Host: "test3", Hostname: "192.168.1.3", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "21", LastLoginTime: "2023-07-01T12:00:00"},
-		{Host: "test2", Hostname: "192.168.1.2", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "20", LastLoginTime: "2023-07-01T12:00:00"},
-		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "20", LastLoginTime: "2023-07-01T10:00:00"},
-		{Host: "test4", Hostname: "192.168.1.4", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "0", LastLoginTime: ""},
+		{Host: "test3", Hostname: "192.168.1.3", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 21, LastLoginTime: loginTime("2023-07-01T12:00:00")},
+		{Host: "test2", Hostname: "192.168.1.2", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 20, LastLoginTime: loginTime("2023-07-01T12:00:00")},
+		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 20, LastLoginTime: loginTime("2023-07-01T10:00:00")},
+		{Host: "test4", Hostname: "192.168.1.4", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 0, LastLoginTime: time.Time{}},
 	}
 
 	for i, expectedConfig := range expectedConfigs {
@@ -69,7 +78,7 @@ Host test4
 			config.Port != expectedConfig.Port ||
 			config.Password != expectedConfig.Password ||
 			config.LoginTimes != expectedConfig.LoginTimes ||
-			config.LastLoginTime != expectedConfig.LastLoginTime {
+			!config.LastLoginTime.Equal(expectedConfig.LastLoginTime) {
 			t.Errorf("Expected config %v, got %v", expectedConfig, config)
 		}
 	}
@@ -92,10 +101,18 @@ func TestWriteConfig(t *testing.T) {
 		Host:          "test",
 		Hostname:      "test.com",
 		User:          "testuser",
-		Port:          "22",
+		Port:          22,
 		Password:      "testpassword",
-		LoginTimes:    "0",
-		LastLoginTime: "2023-07-01T12:00:00",
+		LoginTimes:    0,
+		LastLoginTime: loginTime("2023-07-01T12:00:00"),
+		HostKey:       "SHA256:nThbg6kXUpJWGl7E1IGOCspRomTxdCARLviKw6E5SY8",
+		IdentityFile:  "~/.ssh/id_test",
+		AuthMethods:   "key,password",
+		ProxyJump:     "bastion",
+		Forwards:      []Forward{{Type: ForwardLocal, Listen: "8080", Target: "localhost:80"}, {Type: ForwardDynamic, Listen: "1080"}},
+		Tags:          []string{"env=prod"},
+		Record:        true,
+		LastCheck:     CheckState{Time: time.Date(2023, 7, 2, 8, 0, 0, 0, time.UTC), Status: "ok", Latency: 12 * time.Millisecond},
 	}
 
 	err := WriteConfig(configPath, []SSHConfig{config})
@@ -110,10 +127,10 @@ func TestWriteConfig(t *testing.T) {
 
 func TestListConfigs(t *testing.T) {
 	configs := []SSHConfig{
-		{Host: "test3", Hostname: "192.168.1.3", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "21", LastLoginTime: "2023-07-01T12:00:00"},
-		{Host: "test2", Hostname: "192.168.1.2", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "20", LastLoginTime: "2023-07-01T12:00:00"},
-		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "20", LastLoginTime: "2023-07-01T10:00:00"},
-		{Host: "test4", Hostname: "192.168.1.4", User: "ubuntu", Port: "22", Password: "abcdefg", LoginTimes: "0", LastLoginTime: ""},
+		{Host: "test3", Hostname: "192.168.1.3", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 21, LastLoginTime: loginTime("2023-07-01T12:00:00")},
+		{Host: "test2", Hostname: "192.168.1.2", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 20, LastLoginTime: loginTime("2023-07-01T12:00:00")},
+		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 20, LastLoginTime: loginTime("2023-07-01T10:00:00")},
+		{Host: "test4", Hostname: "192.168.1.4", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 0, LastLoginTime: time.Time{}},
 	}
 
 	ListConfigs(configs)
@@ -121,7 +138,7 @@ func TestListConfigs(t *testing.T) {
 
 func TestGetSSHConfig(t *testing.T) {
 	configs := []SSHConfig{
-		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Password: "abcdefg", LoginTimes: "20", LastLoginTime: "2023-07-01T10:00:00"},
+		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Password: "abcdefg", LoginTimes: 20, LastLoginTime: loginTime("2023-07-01T10:00:00")},
 		{Host: "test2", Hostname: "192.168.1.2", User: "ubuntu", Password: "abc"},
 	}
 	cfg := SSHConfig{Host: "test1"}
@@ -130,4 +147,89 @@ func TestGetSSHConfig(t *testing.T) {
 	if config.Host != "test1" {
 		t.Errorf("Expected host to be 'test1', got '%s'", config.Host)
 	}
+
+	// 同一个 HostName 的多个账号
+	configs = append(configs,
+		SSHConfig{Host: "10.0.0.5", Hostname: "10.0.0.5", User: "root", Port: 22},
+		SSHConfig{Host: "10.0.0.5", Hostname: "10.0.0.5", User: "deploy", Port: 22},
+		SSHConfig{Host: "app", Hostname: "10.0.0.6", User: "deploy", Port: 2222},
+	)
+	if matches := FindSSHConfigs(configs, &SSHConfig{Host: "10.0.0.5", Hostname: "10.0.0.5"}); len(matches) != 2 {
+		t.Errorf("Expected 2 accounts of 10.0.0.5, got %v", matches)
+	}
+	if config, err := GetSSHConfig(&configs, &SSHConfig{Hostname: "10.0.0.5", User: "deploy"}); err != nil || config.User != "deploy" {
+		t.Errorf("Expected deploy@10.0.0.5, got %v (%v)", config, err)
+	}
+	if config, err := GetSSHConfig(&configs, &SSHConfig{Hostname: "app", User: "deploy"}); err != nil || config.Hostname != "10.0.0.6" {
+		t.Errorf("Expected deploy@app by alias, got %v (%v)", config, err)
+	}
+	if _, err := GetSSHConfig(&configs, &SSHConfig{Hostname: "10.0.0.5", User: "admin"}); err == nil {
+		t.Errorf("Expected no account admin@10.0.0.5")
+	}
+	if matches := FindSSHConfigs(configs, &SSHConfig{Hostname: "10.0.0.6", Port: 22}); len(matches) != 0 {
+		t.Errorf("Expected no account on port 22 of 10.0.0.6, got %v", matches)
+	}
+}
+
+func TestParseAuthMethods(t *testing.T) {
+	methods, err := ParseAuthMethods("")
+	if err != nil || len(methods) != 4 || methods[0] != AuthAgent {
+		t.Errorf("Expected default auth methods, got %v (%v)", methods, err)
+	}
+
+	methods, err = ParseAuthMethods("key, password")
+	if err != nil || len(methods) != 2 || methods[0] != AuthKey || methods[1] != AuthPassword {
+		t.Errorf("Expected [key password], got %v (%v)", methods, err)
+	}
+
+	if _, err := ParseAuthMethods("key,gssapi"); err == nil {
+		t.Errorf("Expected error for unknown auth method")
+	}
+}
+
+func TestReadConfigValidation(t *testing.T) {
+	configPath := t.TempDir() + "/config_cache"
+	os.WriteFile(configPath, []byte(`# ssp config_cache version 3
+User orphan
+Host test1
+  HostName 192.168.1.1
+  Port 70000
+  #ssp:LoginTimes many
+Host test2
+  User ubuntu
+  #ssp:LastLoginTime yesterday
+`), 0600)
+
+	_, err := ReadConfig(configPath)
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	for _, s := range []string{":2: User outside of a Host entry", ":5: invalid port \"70000\"", ":6: invalid LoginTimes \"many\"", ":7: host test2 has no HostName", ":9: invalid LastLoginTime"} {
+		if !strings.Contains(err.Error(), s) {
+			t.Errorf("Expected error to contain %q, got:\n%v", s, err)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	now := time.Date(2024, 1, 10, 12, 0, 0, 0, time.UTC)
+	recent := SSHConfig{Host: "recent", LoginTimes: 3, LastLoginTime: now.Add(-10 * time.Minute)}
+	frequent := SSHConfig{Host: "frequent", LoginTimes: 100, LastLoginTime: now.Add(-30 * 24 * time.Hour)}
+	old := SSHConfig{Host: "old", LoginTimes: 10, LastLoginTime: now.Add(-30 * 24 * time.Hour)}
+	never := SSHConfig{Host: "never"}
+
+	if !frequent.compareAt(&recent, now) || !recent.compareAt(&old, now) || !old.compareAt(&never, now) {
+		t.Errorf("Expected frequent > recent > old > never")
+	}
+	// 得分相同时最近登录的优先
+	a := SSHConfig{Host: "a", LoginTimes: 4, LastLoginTime: now.Add(-2 * time.Hour)}
+	b := SSHConfig{Host: "b", LoginTimes: 4, LastLoginTime: now.Add(-3 * time.Hour)}
+	if !a.compareAt(&b, now) || b.compareAt(&a, now) {
+		t.Errorf("Expected a before b")
+	}
+	// 都未登录时按 Host 排序
+	if !(&SSHConfig{Host: "a"}).compareAt(&SSHConfig{Host: "b"}, now) {
+		t.Errorf("Expected hosts without logins sorted by name")
+	}
 }
@@ -0,0 +1,83 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// editHelp 编辑文件开头的说明, 读取时作为注释忽略
+const editHelp = `# Edit the entries below and save to apply, the file is checked like config_cache
+# and reopened when it has errors. Lines starting with # are ignored except #ssp: fields.
+# Removing an entry removes the host, an empty file cancels the edit.
+`
+
+// WriteEditFile 以缓存文件的格式写入 configs, 供在编辑器中修改
+// 开启加密存储时密码写入密文, 改成明文的密码在保存时重新加密
+func WriteEditFile(path string, configs []SSHConfig) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	if _, err := fmt.Fprintf(writer, versionHeader+"\n%s", CurrentVersion, editHelp); err != nil {
+		return err
+	}
+	if err := writeEntries(writer, configs); err != nil {
+		return err
+	}
+	if err := writer.Flush(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// ReadEditFile 读取编辑后的文件, 与 ReadConfig 做相同的校验, 另外检查账号是否重复
+// 出错时返回 ValidationError, 行号对应编辑文件中的行
+func ReadEditFile(path string) ([]SSHConfig, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// 删掉版本头时按当前格式解析
+	lines := strings.Split(string(content), "\n")
+	version, start := detectVersion(lines)
+	if start == 0 {
+		version = CurrentVersion
+	}
+	lines, err = migrateLines(path, lines[start:], version)
+	if err != nil {
+		return nil, err
+	}
+	configs, err := parseConfigLines(path, lines, start)
+	if err != nil {
+		return nil, err
+	}
+
+	var errs ValidationError
+	seen := map[string]bool{}
+	i := 0
+	for n, line := range lines {
+		line = strings.TrimPrefix(strings.TrimSpace(line), metaPrefix)
+		if key, _, _ := strings.Cut(line, " "); key != "Host" {
+			continue
+		}
+		if seen[configs[i].Key()] {
+			errs = append(errs, &LineError{Path: path, Line: start + n + 1, Err: fmt.Errorf("duplicate entry %s", configs[i].Key())})
+		}
+		seen[configs[i].Key()] = true
+		i++
+	}
+	if len(errs) > 0 {
+		return nil, errs
+	}
+
+	if err := decryptConfigs(configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEditFile(t *testing.T) {
+	path := t.TempDir() + "/edit.conf"
+	configs := []SSHConfig{
+		{Host: "node1", Hostname: "10.0.0.1", User: "root", Port: 22, Password: "secret", LoginTimes: 3, LastLoginTime: time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC), Tags: []string{"env=prod"}},
+		{Host: "node2", Hostname: "10.0.0.2", User: "admin", Port: 2222, ProxyJump: "node1"},
+	}
+	if err := WriteEditFile(path, configs); err != nil {
+		t.Fatalf("WriteEditFile: %v", err)
+	}
+	edited, err := ReadEditFile(path)
+	if err != nil {
+		t.Fatalf("ReadEditFile: %v", err)
+	}
+	if len(edited) != len(configs) {
+		t.Fatalf("Expected %d entries, got %d", len(configs), len(edited))
+	}
+	for i := range configs {
+		if !edited[i].Equals(&configs[i]) {
+			t.Errorf("Expected %v, got %v", configs[i], edited[i])
+		}
+	}
+
+	// 删掉版本头和说明也可以读取
+	os.WriteFile(path, []byte("Host node1\n  HostName 10.0.0.1\n  #ssp:Password secret\n"), 0600)
+	if edited, err := ReadEditFile(path); err != nil || len(edited) != 1 || edited[0].Password != "secret" {
+		t.Errorf("Expected node1 without header, got %v (%v)", edited, err)
+	}
+
+	os.WriteFile(path, []byte(`# ssp config_cache version 3
+# comment
+Host node1
+  HostName 10.0.0.1
+  Port 22x
+Host node2
+  HostName 10.0.0.2
+Host node1
+  HostName 10.0.0.3
+`), 0600)
+	_, err = ReadEditFile(path)
+	var verr ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("Expected ValidationError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), ":5: invalid port") {
+		t.Errorf("Expected port error on line 5, got:\n%v", err)
+	}
+
+	os.WriteFile(path, []byte(`# ssp config_cache version 3
+Host node1
+  HostName 10.0.0.1
+  User root
+Host node1
+  HostName 10.0.0.1
+  User deploy
+Host node1
+  HostName 10.0.0.1
+  User root
+  Port 22
+`), 0600)
+	if _, err := ReadEditFile(path); err == nil || !strings.Contains(err.Error(), ":8: duplicate entry node1 root@10.0.0.1:22") || strings.Contains(err.Error(), ":5:") {
+		t.Errorf("Expected duplicate entry on line 8, got %v", err)
+	}
+}
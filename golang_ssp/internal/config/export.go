@@ -0,0 +1,102 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// ExportOpenSSH 输出可以被 ~/.ssh/config Include 的配置片段, 不包含密码等 ssp 专用字段
+// 同一个 Host 有多个账号时 OpenSSH 只会使用第一个, 其他账号导出为 <host>-<user> 等不重复的别名
+func ExportOpenSSH(w io.Writer, configs []SSHConfig) error {
+	aliases := exportAliases(configs)
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "# Generated by ssp, do not edit. Add \"Include <this file>\" to the top of ~/.ssh/config\n\n")
+	for i, c := range configs {
+		alias := aliases[i]
+		if alias != c.Host {
+			fmt.Fprintf(writer, "# Another account of %s\n", c.Host)
+		}
+		fmt.Fprintf(writer, "Host %s\n  HostName %s\n  User %s\n  Port %d\n", alias, c.Hostname, c.User, c.PortOrDefault())
+		if c.IdentityFile != "" {
+			fmt.Fprintf(writer, "  IdentityFile %s\n", c.IdentityFile)
+		}
+		if c.ProxyJump != "" {
+			fmt.Fprintf(writer, "  ProxyJump %s\n", c.ProxyJump)
+		}
+		fmt.Fprintln(writer)
+	}
+	return writer.Flush()
+}
+
+// exportAliases 每个条目导出时使用的 Host, 第一个账号保持原名
+func exportAliases(configs []SSHConfig) []string {
+	used := map[string]bool{}
+	for _, c := range configs {
+		used[c.Host] = true
+	}
+	exported := map[string]bool{}
+	aliases := make([]string, len(configs))
+	for i, c := range configs {
+		alias := c.Host
+		if exported[alias] {
+			alias = c.Host + "-" + c.User
+			for n := 2; used[alias]; n++ {
+				alias = fmt.Sprintf("%s-%s-%d", c.Host, c.User, n)
+			}
+			used[alias] = true
+		}
+		exported[alias] = true
+		aliases[i] = alias
+	}
+	return aliases
+}
+
+// ExportOpenSSHFile 把配置片段写入文件
+func ExportOpenSSHFile(path string, configs []SSHConfig) error {
+	file, err := os.OpenFile(AbsPath(path), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return ExportOpenSSH(file, configs)
+}
+
+// ssh 询问密码时传给 SSH_ASKPASS 的提示:
+// 密码认证 "user@host's password: ", keyboard-interactive "(user@host) Password: "
+var askpassPrompts = []*regexp.Regexp{
+	regexp.MustCompile(`^([^@\s]+)@(\S+)'s password:\s*$`),
+	regexp.MustCompile(`^\(([^@\s]+)@([^)\s]+)\)\s*Password:\s*$`),
+}
+
+// ParseAskpassPrompt 从 ssh 的密码提示中解析用户和主机
+func ParseAskpassPrompt(prompt string) (user string, host string, ok bool) {
+	for _, re := range askpassPrompts {
+		if m := re.FindStringSubmatch(prompt); m != nil {
+			return m[1], m[2], true
+		}
+	}
+	return "", "", false
+}
+
+// LookupAskpass 根据 ssh 的密码提示查找缓存中的密码, 主机可能是 HostName 也可能是别名
+func LookupAskpass(configs []SSHConfig, prompt string) (string, bool) {
+	user, host, ok := ParseAskpassPrompt(prompt)
+	if !ok {
+		return "", false
+	}
+	for _, c := range configs {
+		if c.User == user && strings.EqualFold(c.Hostname, host) && c.Password != "" {
+			return c.Password, true
+		}
+	}
+	for _, c := range configs {
+		if c.User == user && c.Host == host && c.Password != "" {
+			return c.Password, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,84 @@
+package config
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestExportOpenSSH(t *testing.T) {
+	configs := []SSHConfig{
+		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Port: 22, Password: "abcdefg", LoginTimes: 20, HostKey: "SHA256:abc"},
+		{Host: "test2", Hostname: "192.168.1.2", User: "root", Port: 2222, IdentityFile: "~/.ssh/id_ed25519", ProxyJump: "test1"},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportOpenSSH(&buf, configs); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	out := buf.String()
+
+	for _, s := range []string{"abcdefg", "Password", "LoginTimes", "HostKey"} {
+		if strings.Contains(out, s) {
+			t.Errorf("Expected export without %s, got:\n%s", s, out)
+		}
+	}
+	for _, s := range []string{"Host test1\n  HostName 192.168.1.1\n  User ubuntu\n  Port 22\n", "  IdentityFile ~/.ssh/id_ed25519\n", "  ProxyJump test1\n"} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected export to contain %q, got:\n%s", s, out)
+		}
+	}
+}
+
+func TestExportOpenSSHAccounts(t *testing.T) {
+	configs := []SSHConfig{
+		{Host: "app", Hostname: "10.0.0.5", User: "root", Port: 22},
+		{Host: "app", Hostname: "10.0.0.5", User: "deploy", Port: 22},
+		{Host: "app", Hostname: "10.0.0.6", User: "deploy", Port: 22},
+		{Host: "app-root", Hostname: "10.0.0.7", User: "root", Port: 22},
+	}
+
+	var buf bytes.Buffer
+	if err := ExportOpenSSH(&buf, configs); err != nil {
+		t.Fatalf("Failed to export: %v", err)
+	}
+	out := buf.String()
+	for _, s := range []string{
+		"Host app\n  HostName 10.0.0.5\n  User root\n",
+		"Host app-deploy\n  HostName 10.0.0.5\n  User deploy\n",
+		"Host app-deploy-2\n  HostName 10.0.0.6\n  User deploy\n",
+		"Host app-root\n  HostName 10.0.0.7\n",
+	} {
+		if !strings.Contains(out, s) {
+			t.Errorf("Expected export to contain %q, got:\n%s", s, out)
+		}
+	}
+	if strings.Count(out, "Host app\n") != 1 {
+		t.Errorf("Expected each alias to be exported once, got:\n%s", out)
+	}
+}
+
+func TestLookupAskpass(t *testing.T) {
+	configs := []SSHConfig{
+		{Host: "test1", Hostname: "192.168.1.1", User: "ubuntu", Password: "abcdefg"},
+		{Host: "test2", Hostname: "192.168.1.2", User: "root", Password: "123456"},
+	}
+
+	testCases := []struct {
+		prompt   string
+		password string
+		ok       bool
+	}{
+		{"ubuntu@192.168.1.1's password: ", "abcdefg", true},
+		{"(root@192.168.1.2) Password: ", "123456", true},
+		{"root@test2's password: ", "123456", true},
+		{"root@192.168.1.1's password: ", "", false},
+		{"Are you sure you want to continue connecting (yes/no)? ", "", false},
+	}
+	for _, tc := range testCases {
+		password, ok := LookupAskpass(configs, tc.prompt)
+		if password != tc.password || ok != tc.ok {
+			t.Errorf("Prompt %q: expected %q %v, got %q %v", tc.prompt, tc.password, tc.ok, password, ok)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 端口转发类型, 与 ssh -L/-R/-D 对应
+const (
+	ForwardLocal   = "L"
+	ForwardRemote  = "R"
+	ForwardDynamic = "D"
+)
+
+// Forward 保存在主机条目中的端口转发
+type Forward struct {
+	Type   string // L, R, D
+	Listen string // [bind_address:]port
+	Target string // host:hostport, D 类型为空
+}
+
+// forwardKeys OpenSSH 配置中对应的关键字
+var forwardKeys = map[string]string{
+	ForwardLocal:   "LocalForward",
+	ForwardRemote:  "RemoteForward",
+	ForwardDynamic: "DynamicForward",
+}
+
+// ParseForward 解析 ssh 命令行格式的转发, 如 L 8080:localhost:80, D 1080
+func ParseForward(typ string, spec string) (Forward, error) {
+	parts := strings.Split(spec, ":")
+	f := Forward{Type: typ}
+
+	switch typ {
+	case ForwardLocal, ForwardRemote:
+		// [bind_address:]port:host:hostport
+		if len(parts) != 3 && len(parts) != 4 {
+			return f, fmt.Errorf("invalid -%s forward %q, expected [bind_address:]port:host:hostport", typ, spec)
+		}
+		n := len(parts)
+		f.Listen = strings.Join(parts[:n-2], ":")
+		f.Target = parts[n-2] + ":" + parts[n-1]
+		if !isPort(parts[n-1]) {
+			return f, fmt.Errorf("invalid -%s forward %q, bad target port", typ, spec)
+		}
+	case ForwardDynamic:
+		// [bind_address:]port
+		if len(parts) != 1 && len(parts) != 2 {
+			return f, fmt.Errorf("invalid -D forward %q, expected [bind_address:]port", spec)
+		}
+		f.Listen = spec
+	default:
+		return f, fmt.Errorf("unknown forward type %q", typ)
+	}
+
+	listen := strings.Split(f.Listen, ":")
+	if !isPort(listen[len(listen)-1]) {
+		return f, fmt.Errorf("invalid -%s forward %q, bad listen port", typ, spec)
+	}
+	return f, nil
+}
+
+// parseForwardLine 解析配置文件中的 LocalForward/RemoteForward/DynamicForward
+func parseForwardLine(key string, value string) (Forward, error) {
+	for typ, k := range forwardKeys {
+		if k != key {
+			continue
+		}
+		fields := strings.Fields(value)
+		if typ == ForwardDynamic && len(fields) == 1 {
+			return ParseForward(typ, fields[0])
+		}
+		if typ != ForwardDynamic && len(fields) == 2 {
+			return ParseForward(typ, fields[0]+":"+fields[1])
+		}
+		return Forward{}, fmt.Errorf("invalid %s %q", key, value)
+	}
+	return Forward{}, fmt.Errorf("unknown forward keyword %s", key)
+}
+
+func isPort(s string) bool {
+	port, err := strconv.Atoi(s)
+	return err == nil && port > 0 && port < 65536
+}
+
+// ListenAddr 返回监听地址, 没有指定绑定地址时只监听本地回环地址
+func (f Forward) ListenAddr() string {
+	if strings.Contains(f.Listen, ":") {
+		return f.Listen
+	}
+	return "127.0.0.1:" + f.Listen
+}
+
+// Value 返回 ssh 命令行参数值, 如 8080:localhost:80
+func (f Forward) Value() string {
+	if f.Target == "" {
+		return f.Listen
+	}
+	return f.Listen + ":" + f.Target
+}
+
+// Spec 返回 ssh 命令行格式, 如 -L 8080:localhost:80
+func (f Forward) Spec() string {
+	return fmt.Sprintf("-%s %s", f.Type, f.Value())
+}
+
+// String 返回 OpenSSH 配置格式, 如 LocalForward 8080 localhost:80
+func (f Forward) String() string {
+	if f.Target == "" {
+		return fmt.Sprintf("%s %s", forwardKeys[f.Type], f.Listen)
+	}
+	return fmt.Sprintf("%s %s %s", forwardKeys[f.Type], f.Listen, f.Target)
+}
+
+// AddForward 添加端口转发, 已存在时忽略
+func (s *SSHConfig) AddForward(f Forward) {
+	for _, existing := range s.Forwards {
+		if existing == f {
+			return
+		}
+	}
+	s.Forwards = append(s.Forwards, f)
+}
+
+func forwardsEqual(a, b []Forward) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
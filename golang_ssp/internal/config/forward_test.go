@@ -0,0 +1,51 @@
+package config
+
+import "testing"
+
+func TestParseForward(t *testing.T) {
+	testCases := []struct {
+		typ      string
+		spec     string
+		expected Forward
+		line     string
+	}{
+		{ForwardLocal, "8080:localhost:80", Forward{Type: "L", Listen: "8080", Target: "localhost:80"}, "LocalForward 8080 localhost:80"},
+		{ForwardRemote, "0.0.0.0:9000:127.0.0.1:3000", Forward{Type: "R", Listen: "0.0.0.0:9000", Target: "127.0.0.1:3000"}, "RemoteForward 0.0.0.0:9000 127.0.0.1:3000"},
+		{ForwardDynamic, "1080", Forward{Type: "D", Listen: "1080"}, "DynamicForward 1080"},
+	}
+
+	for _, tc := range testCases {
+		f, err := ParseForward(tc.typ, tc.spec)
+		if err != nil || f != tc.expected {
+			t.Errorf("Expected %v, got %v (%v)", tc.expected, f, err)
+		}
+		if f.String() != tc.line {
+			t.Errorf("Expected %q, got %q", tc.line, f.String())
+		}
+
+		// 配置文件格式可以解析回来
+		key, value := splitForwardLine(f.String())
+		parsed, err := parseForwardLine(key, value)
+		if err != nil || parsed != f {
+			t.Errorf("Expected %v, got %v (%v)", f, parsed, err)
+		}
+	}
+
+	for _, spec := range []string{"8080", "abc:localhost:80", "8080:localhost:http"} {
+		if _, err := ParseForward(ForwardLocal, spec); err == nil {
+			t.Errorf("Expected error for -L %s", spec)
+		}
+	}
+	if _, err := ParseForward(ForwardDynamic, "localhost:1080:80"); err == nil {
+		t.Errorf("Expected error for -D localhost:1080:80")
+	}
+}
+
+func splitForwardLine(line string) (string, string) {
+	for i, c := range line {
+		if c == ' ' {
+			return line[:i], line[i+1:]
+		}
+	}
+	return line, ""
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"os"
+	"syscall"
+)
+
+// lockConfig 对缓存文件加排他的建议锁 (flock), 锁文件为 <configPath>.lock
+// 锁文件不删除, 删除会导致不同进程锁住不同的文件
+func lockConfig(configPath string) (func(), error) {
+	file, err := os.OpenFile(AbsPath(configPath)+".lock", os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	if err := syscall.Flock(int(file.Fd()), syscall.LOCK_EX); err != nil {
+		file.Close()
+		return nil, err
+	}
+	return func() {
+		syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+		file.Close()
+	}, nil
+}
+
+// UpdateConfig 在锁内重新读取缓存, 执行修改后写回, 避免多个 ssp 同时修改时丢失数据
+// 返回修改后的最新配置
+func UpdateConfig(configPath string, update func(configs *[]SSHConfig) error) (*[]SSHConfig, error) {
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	configs, err := ReadConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := update(configs); err != nil {
+		return nil, err
+	}
+	SortConfigs(configs)
+	if err := WriteConfig(configPath, *configs); err != nil {
+		return nil, err
+	}
+	return configs, nil
+}
@@ -0,0 +1,45 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestUpdateConfigConcurrent(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	WriteConfig(configPath, []SSHConfig{{Host: "test", Hostname: "127.0.0.1", User: "root", Port: 22, LoginTimes: 0}})
+
+	// 模拟多个 ssp 同时登录
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := UpdateConfig(configPath, func(configs *[]SSHConfig) error {
+				(*configs)[0].LoginTimes++
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Failed to update config: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	cfgs, err := ReadConfig(configPath)
+	if err != nil || len(*cfgs) != 1 || (*cfgs)[0].LoginTimes != 20 {
+		t.Errorf("Expected LoginTimes 20, got %v (%v)", cfgs, err)
+	}
+
+	// 不留下临时文件, 缓存文件只有当前用户可读
+	files, _ := filepath.Glob(filepath.Join(filepath.Dir(configPath), ".config_cache-*"))
+	if len(files) != 0 {
+		t.Errorf("Expected no temp files, got %v", files)
+	}
+	info, _ := os.Stat(configPath)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+}
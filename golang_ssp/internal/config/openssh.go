@@ -0,0 +1,394 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"golang_ssp/golang_ssp/pkg/logger"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 解析 OpenSSH 的 ~/.ssh/config, 用于导入主机到 config_cache
+// 支持 Include、通配符/否定的 Host 模式、Match 块和一行多个别名
+
+const maxIncludeDepth = 16
+
+type sshOption struct {
+	key   string // 小写关键字
+	value string
+}
+
+// sshBlock Host 或 Match 块, 文件开头不属于任何块的配置对所有主机生效
+type sshBlock struct {
+	match    bool
+	patterns []string // Host 模式或 Match 条件
+	options  []sshOption
+	parent   *sshBlock // 在 Host/Match 块中 Include 的块, 需要同时满足外层条件
+}
+
+// OpenSSHConfig 按文件顺序展开 Include 后的所有块
+type OpenSSHConfig struct {
+	blocks  []*sshBlock
+	baseDir string // Include 相对路径的基准目录, 即配置文件所在目录 (通常为 ~/.ssh)
+}
+
+// ParseOpenSSHConfig 解析 OpenSSH 客户端配置文件
+func ParseOpenSSHConfig(path string) (*OpenSSHConfig, error) {
+	path = AbsPath(path)
+	c := &OpenSSHConfig{blocks: []*sshBlock{{patterns: []string{"*"}}}, baseDir: filepath.Dir(path)}
+	if err := c.parseFile(path, c.blocks[0], nil, 0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *OpenSSHConfig) parseFile(path string, current *sshBlock, parent *sshBlock, depth int) error {
+	if depth > maxIncludeDepth {
+		return fmt.Errorf("%s: too many nested includes", path)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	lineNo := 0
+	for scanner.Scan() {
+		lineNo++
+		key, args := splitSSHLine(scanner.Text())
+		if key == "" {
+			continue
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("%s:%d: missing argument for %s", path, lineNo, key)
+		}
+
+		switch key {
+		case "host":
+			current = &sshBlock{patterns: args, parent: parent}
+			c.blocks = append(c.blocks, current)
+		case "match":
+			current = &sshBlock{match: true, patterns: args, parent: parent}
+			c.blocks = append(c.blocks, current)
+		case "include":
+			// Include 的内容属于当前块, 其中的 Host/Match 会开启新块
+			enclosing := current
+			if current == c.blocks[0] {
+				enclosing = parent
+			}
+			before := len(c.blocks)
+			for _, pattern := range args {
+				if !filepath.IsAbs(pattern) && !strings.HasPrefix(pattern, "~") {
+					pattern = filepath.Join(c.baseDir, pattern)
+				}
+				files, err := filepath.Glob(AbsPath(pattern))
+				if err != nil {
+					return fmt.Errorf("%s:%d: %v", path, lineNo, err)
+				}
+				for _, f := range files {
+					if err := c.parseFile(f, current, enclosing, depth+1); err != nil {
+						return err
+					}
+				}
+			}
+			// Include 之后的配置仍属于当前块, 为保持先后顺序复制一个新块
+			if len(c.blocks) != before {
+				current = &sshBlock{match: current.match, patterns: current.patterns, parent: current.parent}
+				c.blocks = append(c.blocks, current)
+			}
+		default:
+			current.options = append(current.options, sshOption{key: key, value: strings.Join(args, " ")})
+		}
+	}
+	return scanner.Err()
+}
+
+// splitSSHLine 拆分 "Key value"、"Key=value" 格式, 支持双引号
+func splitSSHLine(line string) (string, []string) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return "", nil
+	}
+
+	end := strings.IndexAny(line, " \t=")
+	if end < 0 {
+		return strings.ToLower(line), nil
+	}
+	key := strings.ToLower(line[:end])
+	rest := strings.TrimSpace(line[end:])
+	rest = strings.TrimSpace(strings.TrimPrefix(rest, "="))
+
+	var args []string
+	var arg strings.Builder
+	inQuote, hasArg := false, false
+	for _, r := range rest {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+			hasArg = true
+		case !inQuote && (r == ' ' || r == '\t'):
+			if hasArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				hasArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			hasArg = true
+		}
+	}
+	if hasArg {
+		args = append(args, arg.String())
+	}
+	return key, args
+}
+
+// matchPattern OpenSSH 通配符, 只支持 * 和 ?
+func matchPattern(pattern, s string) bool {
+	if pattern == "" {
+		return s == ""
+	}
+	switch pattern[0] {
+	case '*':
+		for i := 0; i <= len(s); i++ {
+			if matchPattern(pattern[1:], s[i:]) {
+				return true
+			}
+		}
+		return false
+	case '?':
+		return s != "" && matchPattern(pattern[1:], s[1:])
+	default:
+		return s != "" && strings.EqualFold(pattern[:1], s[:1]) && matchPattern(pattern[1:], s[1:])
+	}
+}
+
+// matchPatternList 逗号或空格分隔的模式列表, 命中否定模式时不匹配
+func matchPatternList(patterns []string, s string) bool {
+	matched := false
+	for _, p := range patterns {
+		for _, pattern := range strings.Split(p, ",") {
+			if strings.HasPrefix(pattern, "!") {
+				if matchPattern(pattern[1:], s) {
+					return false
+				}
+			} else if matchPattern(pattern, s) {
+				matched = true
+			}
+		}
+	}
+	return matched
+}
+
+func hasWildcard(s string) bool {
+	return strings.ContainsAny(s, "*?!")
+}
+
+// Aliases 返回所有 Host 行中不含通配符的别名
+func (c *OpenSSHConfig) Aliases() []string {
+	var aliases []string
+	seen := map[string]bool{}
+	for _, b := range c.blocks[1:] {
+		if b.match {
+			continue
+		}
+		for _, alias := range b.patterns {
+			if hasWildcard(alias) || seen[alias] {
+				continue
+			}
+			seen[alias] = true
+			aliases = append(aliases, alias)
+		}
+	}
+	return aliases
+}
+
+// resolve 计算别名的最终配置, 与 OpenSSH 一样先出现的值优先, 转发类配置累加
+func (c *OpenSSHConfig) resolve(alias string) map[string][]string {
+	values := map[string][]string{}
+	for _, b := range c.blocks {
+		if !c.matchBlock(b, alias, values) {
+			continue
+		}
+
+		for _, opt := range b.options {
+			switch opt.key {
+			case "localforward", "remoteforward", "dynamicforward", "identityfile":
+				values[opt.key] = append(values[opt.key], opt.value)
+			default:
+				if _, ok := values[opt.key]; !ok {
+					values[opt.key] = []string{opt.value}
+				}
+			}
+		}
+	}
+	return values
+}
+
+func (c *OpenSSHConfig) matchBlock(b *sshBlock, alias string, values map[string][]string) bool {
+	if b.parent != nil && !c.matchBlock(b.parent, alias, values) {
+		return false
+	}
+	if b.match {
+		return c.matchCriteria(b.patterns, alias, values)
+	}
+	return matchPatternList(b.patterns, alias)
+}
+
+// matchCriteria 计算 Match 条件, exec 等无法安全求值的条件视为不匹配
+func (c *OpenSSHConfig) matchCriteria(criteria []string, alias string, values map[string][]string) bool {
+	hostname := alias
+	if v, ok := values["hostname"]; ok {
+		hostname = strings.ReplaceAll(v[0], "%h", alias)
+	}
+	user := localUser()
+	if v, ok := values["user"]; ok {
+		user = v[0]
+	}
+
+	for i := 0; i < len(criteria); i++ {
+		criterion := strings.ToLower(criteria[i])
+		negate := strings.HasPrefix(criterion, "!")
+		criterion = strings.TrimPrefix(criterion, "!")
+
+		var result bool
+		switch criterion {
+		case "all":
+			result = true
+		case "final":
+			result = true
+		case "canonical":
+			result = false
+		case "host", "originalhost", "user", "localuser", "exec", "localnetwork", "tagged", "address", "localaddress", "localport", "rdomain":
+			if i+1 >= len(criteria) {
+				return false
+			}
+			arg := criteria[i+1]
+			i++
+			switch criterion {
+			case "host":
+				result = matchPatternList([]string{arg}, hostname)
+			case "originalhost":
+				result = matchPatternList([]string{arg}, alias)
+			case "user":
+				result = matchPatternList([]string{arg}, user)
+			case "localuser":
+				result = matchPatternList([]string{arg}, localUser())
+			default:
+				logger.Logger.Printf("Match %s is not supported when importing, skipped\n", criterion)
+				return false
+			}
+		default:
+			logger.Logger.Printf("Unknown Match criterion %s, skipped\n", criterion)
+			return false
+		}
+
+		if result == negate {
+			return false
+		}
+	}
+	return true
+}
+
+func localUser() string {
+	if user := os.Getenv("USER"); user != "" {
+		return user
+	}
+	return "root"
+}
+
+// Entries 把所有具体别名展开成 SSHConfig
+func (c *OpenSSHConfig) Entries() []SSHConfig {
+	var entries []SSHConfig
+	for _, alias := range c.Aliases() {
+		values := c.resolve(alias)
+		first := func(key, def string) string {
+			if v, ok := values[key]; ok {
+				return v[0]
+			}
+			return def
+		}
+
+		port, err := ParsePort(first("port", ""))
+		if err != nil {
+			logger.Logger.Printf("Ignore Port of host %s: %v\n", alias, err)
+			port = DefaultPort
+		}
+		cfg := SSHConfig{
+			Host:     alias,
+			Hostname: strings.ReplaceAll(first("hostname", alias), "%h", alias),
+			User:     first("user", ""), // 未指定时在合并时决定, 避免覆盖缓存中的账号
+			Port:     port,
+		}
+		if identity := first("identityfile", ""); identity != "" && !strings.EqualFold(identity, "none") {
+			cfg.IdentityFile = identity
+		}
+		if jump := first("proxyjump", ""); jump != "" && !strings.EqualFold(jump, "none") {
+			if strings.ContainsAny(jump, "@:,") {
+				logger.Logger.Printf("ProxyJump %s of host %s is not a single alias, skipped\n", jump, alias)
+			} else {
+				cfg.ProxyJump = jump
+			}
+		}
+		for typ, key := range forwardKeys {
+			for _, value := range values[strings.ToLower(key)] {
+				f, err := parseForwardLine(key, value)
+				if err != nil {
+					logger.Logger.Printf("Ignore %s of host %s: %v\n", key, alias, err)
+					continue
+				}
+				f.Type = typ
+				cfg.AddForward(f)
+			}
+		}
+		entries = append(entries, cfg)
+	}
+	return entries
+}
+
+// MergeConfigs 合并导入的条目, 按账号 (Host、User 和地址) 匹配, 已存在的账号只补充缺少的
+// IdentityFile、ProxyJump 和端口转发, 保留密码、登录统计和主机密钥; 用户或地址不同时添加为新账号.
+// 导入的条目没有指定 User 时匹配同一主机同一地址的任意账号, 都不匹配时使用本地用户名添加
+func MergeConfigs(cfgs *[]SSHConfig, imported []SSHConfig) (added int, updated int) {
+	for _, cfg := range imported {
+		existing := findImported(*cfgs, &cfg)
+		if existing == nil {
+			if cfg.User == "" {
+				cfg.User = localUser()
+			}
+			*cfgs = append(*cfgs, cfg)
+			added++
+			continue
+		}
+		changed := false
+		if existing.IdentityFile == "" && cfg.IdentityFile != "" {
+			existing.IdentityFile = cfg.IdentityFile
+			changed = true
+		}
+		if existing.ProxyJump == "" && cfg.ProxyJump != "" {
+			existing.ProxyJump = cfg.ProxyJump
+			changed = true
+		}
+		forwards := len(existing.Forwards)
+		for _, f := range cfg.Forwards {
+			existing.AddForward(f)
+		}
+		if changed || len(existing.Forwards) != forwards {
+			updated++
+		}
+	}
+	SortConfigs(cfgs)
+	return added, updated
+}
+
+func findImported(cfgs []SSHConfig, cfg *SSHConfig) *SSHConfig {
+	for i := range cfgs {
+		c := &cfgs[i]
+		if c.Host == cfg.Host && c.Address() == cfg.Address() && (cfg.User == "" || c.User == cfg.User) {
+			return c
+		}
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseOpenSSHConfig(t *testing.T) {
+	dir := t.TempDir()
+	os.MkdirAll(filepath.Join(dir, "config.d"), 0755)
+
+	os.WriteFile(filepath.Join(dir, "config"), []byte(`
+# 全局配置
+Include config.d/*
+
+Host web1 web2 !web3
+  HostName %h.example.com
+  User deploy
+
+Host db1
+  HostName=10.0.0.5
+  Port 2222
+  IdentityFile "~/.ssh/id db"
+  LocalForward 5432 localhost:5432
+
+Match originalhost db* user deploy
+  Port 3333
+
+Match originalhost db*
+  ProxyJump bastion
+
+Match exec "true"
+  User exec
+
+Host *
+  User root
+  Port 22
+`), 0644)
+	os.WriteFile(filepath.Join(dir, "config.d", "bastion"), []byte(`
+Host bastion jump
+  HostName 1.2.3.4
+  User ops
+`), 0644)
+
+	c, err := ParseOpenSSHConfig(filepath.Join(dir, "config"))
+	if err != nil {
+		t.Fatalf("Failed to parse config: %v", err)
+	}
+
+	entries := map[string]SSHConfig{}
+	for _, e := range c.Entries() {
+		entries[e.Host] = e
+	}
+	if len(entries) != 5 {
+		t.Fatalf("Expected 5 entries, got %v", entries)
+	}
+
+	testCases := []SSHConfig{
+		{Host: "bastion", Hostname: "1.2.3.4", User: "ops", Port: 22},
+		{Host: "jump", Hostname: "1.2.3.4", User: "ops", Port: 22},
+		{Host: "web1", Hostname: "web1.example.com", User: "deploy", Port: 22},
+		{Host: "web2", Hostname: "web2.example.com", User: "deploy", Port: 22},
+		{Host: "db1", Hostname: "10.0.0.5", User: "root", Port: 2222, IdentityFile: "~/.ssh/id db", ProxyJump: "bastion",
+			Forwards: []Forward{{Type: ForwardLocal, Listen: "5432", Target: "localhost:5432"}}},
+	}
+	for _, expected := range testCases {
+		e := entries[expected.Host]
+		if e.Hostname != expected.Hostname || e.User != expected.User || e.Port != expected.Port ||
+			e.IdentityFile != expected.IdentityFile || e.ProxyJump != expected.ProxyJump || !forwardsEqual(e.Forwards, expected.Forwards) {
+			t.Errorf("Expected %v, got %v", expected, e)
+		}
+	}
+}
+
+func TestMergeConfigs(t *testing.T) {
+	t.Setenv("USER", "local")
+	cfgs := []SSHConfig{
+		{Host: "db1", Hostname: "10.0.0.1", User: "root", Port: 22, Password: "secret", LoginTimes: 7, LastLoginTime: loginTime("2023-07-01T12:00:00"), HostKey: "SHA256:abc"},
+		{Host: "app", Hostname: "10.0.0.2", User: "deploy", Port: 22, IdentityFile: "~/.ssh/deploy"},
+	}
+	imported := []SSHConfig{
+		{Host: "db1", Hostname: "10.0.0.1", User: "root", Port: 22, IdentityFile: "~/.ssh/id_db", ProxyJump: "bastion"},
+		{Host: "db1", Hostname: "10.0.0.5", User: "root", Port: 2222},
+		{Host: "app", Hostname: "10.0.0.2", Port: 22, IdentityFile: "~/.ssh/other"},
+		{Host: "web1", Hostname: "web1.example.com", Port: 22},
+	}
+
+	added, updated := MergeConfigs(&cfgs, imported)
+	if added != 2 || updated != 1 {
+		t.Errorf("Expected 2 added and 1 updated, got %d and %d", added, updated)
+	}
+
+	// 已有账号只补充缺少的字段, 不修改地址、密码和统计
+	found := FindSSHConfigs(cfgs, &SSHConfig{Host: "db1"})
+	if len(found) != 2 {
+		t.Fatalf("Expected the new address of db1 to be added as another account, got %v", found)
+	}
+	for _, cfg := range found {
+		if cfg.Hostname != "10.0.0.1" {
+			continue
+		}
+		if cfg.Port != 22 || cfg.IdentityFile != "~/.ssh/id_db" || cfg.ProxyJump != "bastion" {
+			t.Errorf("Expected missing fields to be filled, got %v", cfg)
+		}
+		if cfg.Password != "secret" || cfg.LoginTimes != 7 || !cfg.LastLoginTime.Equal(loginTime("2023-07-01T12:00:00")) || cfg.HostKey != "SHA256:abc" {
+			t.Errorf("Expected password and statistics to be kept, got %v", cfg)
+		}
+	}
+
+	// 没有指定 User 时匹配已有账号, 不覆盖已有的 IdentityFile
+	if app, _ := GetSSHConfig(&cfgs, &SSHConfig{Host: "app"}); app.User != "deploy" || app.IdentityFile != "~/.ssh/deploy" {
+		t.Errorf("Expected app to be kept, got %v", app)
+	}
+	if web, _ := GetSSHConfig(&cfgs, &SSHConfig{Host: "web1"}); web.User != "local" {
+		t.Errorf("Expected new host to use the local user, got %v", web)
+	}
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// config_cache 格式版本
+//
+//	1: 没有版本头, ssp 专用字段写成注释 #Password、#LoginTimes、#LastLoginTime
+//	2: 文件头 "# ssp config_cache version 2", ssp 专用字段写成 #ssp:Password 等
+//	3: LastLoginTime 使用带时区的 RFC 3339 格式 (UTC), 从未登录时不写;
+//	   开启加密存储时版本头之后有一行 #ssp:Vault on
+const CurrentVersion = 3
+
+const (
+	versionHeader = "# ssp config_cache version %d"
+	metaPrefix    = "#ssp:"
+)
+
+var versionRe = regexp.MustCompile(`^# ssp config_cache version (\d+)$`)
+
+// NewerVersionError 缓存文件由更新版本的 ssp 写入, 当前版本无法识别
+type NewerVersionError struct {
+	Path    string
+	Version int
+}
+
+func (e *NewerVersionError) Error() string {
+	return fmt.Sprintf("%s was written by a newer ssp (format version %d, this ssp supports up to %d), please upgrade ssp", e.Path, e.Version, CurrentVersion)
+}
+
+// migration 把 from 版本的内容 (不含版本头) 升级到 from+1 版本, 需要保持行数不变以便报错时定位行号
+type migration struct {
+	from    int
+	migrate func(lines []string) ([]string, error)
+}
+
+var migrations = []migration{
+	{from: 1, migrate: migrateV1ToV2},
+	{from: 2, migrate: migrateV2ToV3},
+}
+
+// detectVersion 返回文件格式版本和正文开始的行下标
+func detectVersion(lines []string) (int, int) {
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if m := versionRe.FindStringSubmatch(line); m != nil {
+			version, _ := strconv.Atoi(m[1])
+			return version, i + 1
+		}
+		break
+	}
+	return 1, 0
+}
+
+// migrateLines 依次执行迁移, 升级到当前版本
+func migrateLines(path string, lines []string, version int) ([]string, error) {
+	if version > CurrentVersion {
+		return nil, &NewerVersionError{Path: path, Version: version}
+	}
+	for version < CurrentVersion {
+		found := false
+		for _, m := range migrations {
+			if m.from != version {
+				continue
+			}
+			migrated, err := m.migrate(lines)
+			if err != nil {
+				return nil, fmt.Errorf("migrate %s from version %d: %w", path, version, err)
+			}
+			lines = migrated
+			found = true
+			break
+		}
+		if !found {
+			return nil, fmt.Errorf("no migration for %s from version %d", path, version)
+		}
+		version++
+	}
+	return lines, nil
+}
+
+// migrateV1ToV2 把 #Password 等注释改写为 #ssp:Password
+func migrateV1ToV2(lines []string) ([]string, error) {
+	v1Keys := []string{"Password", "LoginTimes", "LastLoginTime", "HostKey", "AuthMethods"}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = line
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(trimmed, "#")), " ", 2)
+		for _, key := range v1Keys {
+			if fields[0] == key {
+				indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+				result[i] = indent + metaPrefix + strings.Join(fields, " ")
+				break
+			}
+		}
+	}
+	return result, nil
+}
+
+// neverLoggedIn 旧版本在从未登录时写入的占位时间
+const neverLoggedIn = "1977-01-01T15:04:05"
+
+// migrateV2ToV3 把本地时间格式的 LastLoginTime 转换为 UTC 的 RFC 3339 格式
+func migrateV2ToV3(lines []string) ([]string, error) {
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		result[i] = line
+		trimmed := strings.TrimSpace(line)
+		if !strings.HasPrefix(trimmed, metaPrefix+"LastLoginTime") {
+			continue
+		}
+		fields := strings.Fields(strings.TrimPrefix(trimmed, metaPrefix))
+		if len(fields) != 2 {
+			continue
+		}
+		if fields[1] == neverLoggedIn {
+			result[i] = ""
+			continue
+		}
+		t, err := time.ParseInLocation(TIMEFORMAT, fields[1], time.Local)
+		if err != nil {
+			// 无法识别的值保持原样, 读取时报告行号
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+		result[i] = indent + metaPrefix + "LastLoginTime " + t.UTC().Format(time.RFC3339)
+	}
+	return result, nil
+}
+
+// MigrateConfig 把旧版本的缓存文件升级到当前版本, 升级前备份为 <path>.v<N>.bak
+// 缓存文件由更新版本的 ssp 写入时返回 NewerVersionError
+func MigrateConfig(configPath string) error {
+	configPath = AbsPath(configPath)
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return nil
+	}
+
+	unlock, err := lockConfig(configPath)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	content, err := os.ReadFile(configPath)
+	if err != nil {
+		return err
+	}
+	lines := strings.Split(string(content), "\n")
+	version, start := detectVersion(lines)
+	if version == CurrentVersion || strings.TrimSpace(string(content)) == "" {
+		return nil
+	}
+
+	migrated, err := migrateLines(configPath, lines[start:], version)
+	if err != nil {
+		return err
+	}
+
+	backup := fmt.Sprintf("%s.v%d.bak", configPath, version)
+	if err := os.WriteFile(backup, content, 0600); err != nil {
+		return fmt.Errorf("backup %s: %w", configPath, err)
+	}
+
+	header := fmt.Sprintf(versionHeader, CurrentVersion)
+	err = writeFileAtomic(configPath, func(file *os.File) error {
+		_, err := file.WriteString(header + "\n" + strings.Join(migrated, "\n"))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Migrated %s from version %d to %d, backup saved to %s\n", configPath, version, CurrentVersion, backup)
+	return nil
+}
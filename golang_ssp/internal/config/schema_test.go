@@ -0,0 +1,84 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const v1Config = `Host test1
+  HostName 192.168.1.1
+  User ubuntu
+  Port 22
+  #Password abcdefg
+  #LoginTimes 20
+  #LastLoginTime 2022-01-01T15:04:05
+  #HostKey SHA256:abc
+# a plain comment
+`
+
+func TestMigrateConfig(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	os.WriteFile(configPath, []byte(v1Config), 0600)
+
+	if err := MigrateConfig(configPath); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	backup, err := os.ReadFile(configPath + ".v1.bak")
+	if err != nil || string(backup) != v1Config {
+		t.Errorf("Expected backup with original content, got %q (%v)", backup, err)
+	}
+
+	content, _ := os.ReadFile(configPath)
+	for _, s := range []string{"# ssp config_cache version 3\n", "  #ssp:Password abcdefg\n", "  #ssp:HostKey SHA256:abc\n", "# a plain comment\n"} {
+		if !strings.Contains(string(content), s) {
+			t.Errorf("Expected migrated config to contain %q, got:\n%s", s, content)
+		}
+	}
+
+	cfgs, err := ReadConfig(configPath)
+	if err != nil || len(*cfgs) != 1 {
+		t.Fatalf("Failed to read migrated config: %v %v", cfgs, err)
+	}
+	cfg := (*cfgs)[0]
+	lastLogin := time.Date(2022, 1, 1, 15, 4, 5, 0, time.Local)
+	if cfg.Password != "abcdefg" || cfg.LoginTimes != 20 || !cfg.LastLoginTime.Equal(lastLogin) || cfg.HostKey != "SHA256:abc" {
+		t.Errorf("Unexpected migrated config %+v", cfg)
+	}
+
+	// 已是当前版本时不再迁移
+	os.Remove(configPath + ".v1.bak")
+	if err := MigrateConfig(configPath); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+	if _, err := os.Stat(configPath + ".v1.bak"); !os.IsNotExist(err) {
+		t.Errorf("Expected no backup for current version")
+	}
+}
+
+func TestMigrateV2ToV3(t *testing.T) {
+	lines := []string{"Host test1", "  #ssp:LoginTimes 0", "  #ssp:LastLoginTime 1977-01-01T15:04:05", "Host test2", "  #ssp:LastLoginTime 2023-07-01T12:00:00"}
+	migrated, _ := migrateV2ToV3(lines)
+
+	expected := "  #ssp:LastLoginTime " + time.Date(2023, 7, 1, 12, 0, 0, 0, time.Local).UTC().Format(time.RFC3339)
+	if len(migrated) != len(lines) || migrated[2] != "" || migrated[4] != expected {
+		t.Errorf("Unexpected migration result %q", migrated)
+	}
+}
+
+func TestReadConfigNewerVersion(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	os.WriteFile(configPath, []byte("# ssp config_cache version 99\nHost test1\n  HostName 192.168.1.1\n"), 0600)
+
+	var newer *NewerVersionError
+	if _, err := ReadConfig(configPath); !errors.As(err, &newer) || newer.Version != 99 {
+		t.Errorf("Expected NewerVersionError, got %v", err)
+	}
+	if err := MigrateConfig(configPath); !errors.As(err, &newer) {
+		t.Errorf("Expected NewerVersionError, got %v", err)
+	}
+}
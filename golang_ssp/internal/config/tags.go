@@ -0,0 +1,194 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// ParseTags 解析逗号分隔的标签, 标签可以是 web 或 env=prod 的形式
+func ParseTags(tags string) ([]string, error) {
+	var result []string
+	for _, tag := range strings.Split(tags, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "" {
+			continue
+		}
+		if strings.ContainsAny(tag, " \t!@*?[]") || strings.HasPrefix(tag, "=") || strings.Count(tag, "=") > 1 {
+			return nil, fmt.Errorf("invalid tag %q, expected name or key=value", tag)
+		}
+		result = append(result, tag)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("no tags given")
+	}
+	return result, nil
+}
+
+// AddTags 添加标签, 已存在时忽略; 同一个 key 只保留一个值, 如 env=prod 会替换 env=test
+func (s *SSHConfig) AddTags(tags ...string) {
+	for _, tag := range tags {
+		if key, _, ok := strings.Cut(tag, "="); ok {
+			s.RemoveTags(key)
+		}
+		if !s.HasTag(tag) {
+			s.Tags = append(s.Tags, tag)
+		}
+	}
+}
+
+// RemoveTags 删除标签, 只给出 key 时删除该 key 的所有值
+func (s *SSHConfig) RemoveTags(tags ...string) {
+	var kept []string
+	for _, existing := range s.Tags {
+		remove := false
+		for _, tag := range tags {
+			key, _, _ := strings.Cut(existing, "=")
+			if existing == tag || (!strings.Contains(tag, "=") && key == tag) {
+				remove = true
+				break
+			}
+		}
+		if !remove {
+			kept = append(kept, existing)
+		}
+	}
+	s.Tags = kept
+}
+
+func (s *SSHConfig) HasTag(tag string) bool {
+	for _, existing := range s.Tags {
+		if existing == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func tagsEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Selector 选择一组主机
+//
+//	node1        Host 或 HostName 等于 node1
+//	web*         Host 匹配通配符
+//	@env=prod    标签表达式, 逗号表示同时满足, ! 表示取反, 值支持通配符, 如 @env=prod,role=db,!legacy
+//	@*           所有主机
+type Selector struct {
+	name  string
+	terms []tagTerm
+	all   bool
+}
+
+type tagTerm struct {
+	pattern string
+	negate  bool
+}
+
+// ParseSelector 解析主机名、通配符或 @ 开头的标签表达式
+func ParseSelector(selector string) (*Selector, error) {
+	selector = strings.TrimSpace(selector)
+	if selector == "" {
+		return nil, fmt.Errorf("empty host selector")
+	}
+	if !strings.HasPrefix(selector, "@") {
+		if _, err := path.Match(selector, ""); err != nil {
+			return nil, fmt.Errorf("invalid host pattern %q: %v", selector, err)
+		}
+		return &Selector{name: selector}, nil
+	}
+	expr := strings.TrimPrefix(selector, "@")
+	if expr == "*" {
+		return &Selector{all: true}, nil
+	}
+	terms, err := parseTagExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{terms: terms}, nil
+}
+
+// ParseTagFilter 解析 -list 使用的标签表达式, 不需要 @ 前缀
+func ParseTagFilter(expr string) (*Selector, error) {
+	terms, err := parseTagExpr(expr)
+	if err != nil {
+		return nil, err
+	}
+	return &Selector{terms: terms}, nil
+}
+
+func parseTagExpr(expr string) ([]tagTerm, error) {
+	var terms []tagTerm
+	for _, term := range strings.Split(expr, ",") {
+		term = strings.TrimSpace(term)
+		t := tagTerm{pattern: strings.TrimPrefix(term, "!"), negate: strings.HasPrefix(term, "!")}
+		if t.pattern == "" {
+			return nil, fmt.Errorf("invalid tag expression %q", expr)
+		}
+		if _, err := path.Match(t.pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid tag expression %q: %v", expr, err)
+		}
+		terms = append(terms, t)
+	}
+	return terms, nil
+}
+
+// Match 主机是否属于该选择器
+func (sel *Selector) Match(c *SSHConfig) bool {
+	if sel.all {
+		return true
+	}
+	if sel.name != "" {
+		if c.Host == sel.name || c.Hostname == sel.name {
+			return true
+		}
+		ok, _ := path.Match(sel.name, c.Host)
+		return ok
+	}
+	for _, term := range sel.terms {
+		if term.match(c) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// term 不带 = 时匹配同名标签或该 key 的任意值
+func (t tagTerm) match(c *SSHConfig) bool {
+	for _, tag := range c.Tags {
+		if ok, _ := path.Match(t.pattern, tag); ok {
+			return true
+		}
+		if key, _, found := strings.Cut(tag, "="); found && !strings.Contains(t.pattern, "=") {
+			if ok, _ := path.Match(t.pattern, key); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// IsGroup 是否可能选中多个主机
+func (sel *Selector) IsGroup() bool {
+	return sel.all || sel.terms != nil || strings.ContainsAny(sel.name, "*?[")
+}
+
+// Select 返回选中的主机, 保持缓存中的顺序
+func (sel *Selector) Select(configs []SSHConfig) []SSHConfig {
+	var result []SSHConfig
+	for _, c := range configs {
+		if sel.Match(&c) {
+			result = append(result, c)
+		}
+	}
+	return result
+}
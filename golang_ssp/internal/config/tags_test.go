@@ -0,0 +1,84 @@
+package config
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestTags(t *testing.T) {
+	cfg := SSHConfig{Host: "db1"}
+	cfg.AddTags("env=test", "role=db", "backup")
+	cfg.AddTags("env=prod", "backup")
+	if !tagsEqual(cfg.Tags, []string{"role=db", "backup", "env=prod"}) {
+		t.Errorf("Unexpected tags %v", cfg.Tags)
+	}
+	cfg.RemoveTags("role", "backup")
+	if !tagsEqual(cfg.Tags, []string{"env=prod"}) {
+		t.Errorf("Unexpected tags %v", cfg.Tags)
+	}
+
+	if _, err := ParseTags("env=prod, role=db"); err != nil {
+		t.Errorf("Failed to parse tags: %v", err)
+	}
+	for _, tags := range []string{"", "a b", "@x", "a=b=c", "=x"} {
+		if _, err := ParseTags(tags); err == nil {
+			t.Errorf("Expected error for tags %q", tags)
+		}
+	}
+}
+
+func TestSelector(t *testing.T) {
+	cfgs := []SSHConfig{
+		{Host: "web1", Hostname: "10.0.0.1", Tags: []string{"env=prod", "role=web"}},
+		{Host: "web2", Hostname: "10.0.0.2", Tags: []string{"env=staging", "role=web"}},
+		{Host: "db1", Hostname: "10.0.0.5", Tags: []string{"env=prod", "role=db", "legacy"}},
+		{Host: "db2", Hostname: "10.0.0.6", Tags: []string{"env=prod", "role=db"}},
+	}
+
+	testCases := []struct {
+		selector string
+		hosts    []string
+	}{
+		{"web1", []string{"web1"}},
+		{"10.0.0.5", []string{"db1"}},
+		{"web*", []string{"web1", "web2"}},
+		{"@env=prod", []string{"web1", "db1", "db2"}},
+		{"@env=prod,role=db", []string{"db1", "db2"}},
+		{"@env=prod,role=db,!legacy", []string{"db2"}},
+		{"@env", []string{"web1", "web2", "db1", "db2"}},
+		{"@env=stag*", []string{"web2"}},
+		{"@*", []string{"web1", "web2", "db1", "db2"}},
+		{"@missing", nil},
+	}
+	for _, tc := range testCases {
+		sel, err := ParseSelector(tc.selector)
+		if err != nil {
+			t.Fatalf("Failed to parse %q: %v", tc.selector, err)
+		}
+		var hosts []string
+		for _, c := range sel.Select(cfgs) {
+			hosts = append(hosts, c.Host)
+		}
+		if !tagsEqual(hosts, tc.hosts) {
+			t.Errorf("Selector %q: expected %v, got %v", tc.selector, tc.hosts, hosts)
+		}
+	}
+
+	for _, selector := range []string{"", "@", "@a,,b", "web["} {
+		if _, err := ParseSelector(selector); err == nil {
+			t.Errorf("Expected error for selector %q", selector)
+		}
+	}
+}
+
+func TestTagsPersisted(t *testing.T) {
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	cfg := SSHConfig{Host: "db1", Hostname: "10.0.0.5", Port: 22, Tags: []string{"env=prod", "role=db"}}
+	if err := WriteConfig(configPath, []SSHConfig{cfg}); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfgs, err := ReadConfig(configPath)
+	if err != nil || !(*cfgs)[0].Equals(&cfg) {
+		t.Errorf("Expected %v, got %v (%v)", cfg, cfgs, err)
+	}
+}
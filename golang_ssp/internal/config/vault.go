@@ -0,0 +1,245 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/prompt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+// 密码加密存储：AES-256-GCM，密钥由主口令经 scrypt 派生
+// 密文格式: enc:base64(salt | nonce | ciphertext)
+
+const (
+	encryptedPrefix = "enc:"
+	PassphraseEnv   = "SSP_PASSPHRASE"
+	saltSize        = 16
+	keySize         = 32
+
+	// vaultMarker 写在版本头之后, 表示缓存开启了加密存储, 没有任何密码时也保持开启
+	vaultMarker = metaPrefix + "Vault on"
+)
+
+var (
+	passphrase string
+	vault      bool
+	// 同一进程写入的所有条目共用一个 salt，避免每个条目都做一次 scrypt
+	writeSalt []byte
+	// key 缓存, salt -> key
+	derivedKeys = map[string][]byte{}
+
+	// PassphraseFunc 在读取到加密密码但尚未设置主口令时调用
+	PassphraseFunc = func() (string, error) {
+		return PromptPassphrase("Enter master passphrase", false)
+	}
+	// NewPassphraseFunc 加密存储中还没有密文可以校验主口令时调用, 需要输入两次, 避免输错后密码无法解密
+	NewPassphraseFunc = func() (string, error) {
+		return PromptPassphrase("Enter master passphrase", true)
+	}
+
+	ErrWrongPassphrase = errors.New("wrong master passphrase or corrupted password")
+)
+
+// SetPassphrase 设置主口令并开启加密存储，设置为空则关闭加密存储
+func SetPassphrase(p string) {
+	passphrase = p
+	vault = p != ""
+	writeSalt = nil
+	derivedKeys = map[string][]byte{}
+}
+
+// VaultEnabled 返回是否以加密方式写入密码, 由缓存文件中的 #ssp:Vault on 决定
+func VaultEnabled() bool {
+	return vault
+}
+
+// ensurePassphrase 需要解密但还没有主口令时通过 PassphraseFunc 获取
+func ensurePassphrase() error {
+	if passphrase != "" {
+		return nil
+	}
+	p, err := PassphraseFunc()
+	if err != nil {
+		return fmt.Errorf("read master passphrase: %w", err)
+	}
+	passphrase = p
+	return nil
+}
+
+// ensureWritePassphrase 需要加密但还没有主口令时获取主口令: configs 中有密文时先解密校验,
+// 没有密文 (只有 #ssp:Vault on) 时要求输入两次
+func ensureWritePassphrase(configs []SSHConfig) error {
+	if passphrase != "" {
+		return nil
+	}
+	for _, c := range configs {
+		if !IsEncrypted(c.Password) {
+			continue
+		}
+		if err := ensurePassphrase(); err != nil {
+			return err
+		}
+		if _, err := DecryptPassword(c.Password); err != nil {
+			passphrase = ""
+			return fmt.Errorf("check master passphrase with host %s: %w", c.Host, err)
+		}
+		return nil
+	}
+	p, err := NewPassphraseFunc()
+	if err != nil {
+		return fmt.Errorf("read master passphrase: %w", err)
+	}
+	passphrase = p
+	return nil
+}
+
+func IsEncrypted(v string) bool {
+	return strings.HasPrefix(v, encryptedPrefix)
+}
+
+func deriveKey(salt []byte) ([]byte, error) {
+	if key, ok := derivedKeys[string(salt)]; ok {
+		return key, nil
+	}
+	key, err := scrypt.Key([]byte(passphrase), salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return nil, err
+	}
+	derivedKeys[string(salt)] = key
+	return key, nil
+}
+
+func newGCM(salt []byte) (cipher.AEAD, error) {
+	key, err := deriveKey(salt)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptPassword 用主口令加密密码，空密码不加密
+func EncryptPassword(plain string) (string, error) {
+	if plain == "" || IsEncrypted(plain) {
+		return plain, nil
+	}
+	if passphrase == "" {
+		return "", errors.New("master passphrase not set")
+	}
+
+	if writeSalt == nil {
+		writeSalt = make([]byte, saltSize)
+		if _, err := io.ReadFull(rand.Reader, writeSalt); err != nil {
+			return "", err
+		}
+	}
+
+	gcm, err := newGCM(writeSalt)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", err
+	}
+
+	data := append([]byte{}, writeSalt...)
+	data = append(data, nonce...)
+	data = gcm.Seal(data, nonce, []byte(plain), nil)
+
+	return encryptedPrefix + base64.RawStdEncoding.EncodeToString(data), nil
+}
+
+// DecryptPassword 解密 EncryptPassword 的结果，非加密的值原样返回
+func DecryptPassword(v string) (string, error) {
+	if !IsEncrypted(v) {
+		return v, nil
+	}
+	if passphrase == "" {
+		return "", errors.New("master passphrase not set")
+	}
+
+	data, err := base64.RawStdEncoding.DecodeString(strings.TrimPrefix(v, encryptedPrefix))
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	if len(data) < saltSize {
+		return "", ErrWrongPassphrase
+	}
+
+	salt := data[:saltSize]
+	gcm, err := newGCM(salt)
+	if err != nil {
+		return "", err
+	}
+
+	data = data[saltSize:]
+	if len(data) < gcm.NonceSize() {
+		return "", ErrWrongPassphrase
+	}
+	plain, err := gcm.Open(nil, data[:gcm.NonceSize()], data[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", ErrWrongPassphrase
+	}
+	return string(plain), nil
+}
+
+// PromptPassphrase 从终端读取主口令（不回显），confirm 为 true 时要求输入两次
+// 提示输出到 stderr, 避免作为 SSH_ASKPASS 运行时混入输出的密码
+func PromptPassphrase(label string, confirm bool) (string, error) {
+	if p := os.Getenv(PassphraseEnv); p != "" {
+		return p, nil
+	}
+
+	p := prompt.NewPrompter(os.Stdin, os.Stderr)
+	passphrase, err := p.Secret(label, nil)
+	if err != nil {
+		return "", err
+	}
+	passphrase = strings.TrimSpace(passphrase)
+	if passphrase == "" {
+		return "", errors.New("passphrase cannot be empty")
+	}
+
+	if confirm {
+		repeated, err := p.Secret("Repeat master passphrase", nil)
+		if err != nil {
+			return "", err
+		}
+		if passphrase != strings.TrimSpace(repeated) {
+			return "", errors.New("passphrases do not match")
+		}
+	}
+	return passphrase, nil
+}
+
+// decryptConfigs 解密读取到的配置，必要时询问主口令
+// 没有 #ssp:Vault on 的旧缓存中有密文时同样视为开启了加密存储
+func decryptConfigs(configs []SSHConfig) error {
+	for i := range configs {
+		if !IsEncrypted(configs[i].Password) {
+			continue
+		}
+		vault = true
+		if err := ensurePassphrase(); err != nil {
+			return err
+		}
+		plain, err := DecryptPassword(configs[i].Password)
+		if err != nil {
+			return fmt.Errorf("decrypt password of host %s: %w", configs[i].Host, err)
+		}
+		configs[i].Password = plain
+	}
+	return nil
+}
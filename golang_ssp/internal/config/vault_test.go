@@ -0,0 +1,136 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestEncryptPassword(t *testing.T) {
+	SetPassphrase("master")
+	defer SetPassphrase("")
+
+	enc, err := EncryptPassword("abcdefg")
+	if err != nil {
+		t.Fatalf("Failed to encrypt password: %v", err)
+	}
+	if !IsEncrypted(enc) || strings.Contains(enc, "abcdefg") {
+		t.Fatalf("Expected encrypted password, got %s", enc)
+	}
+
+	plain, err := DecryptPassword(enc)
+	if err != nil || plain != "abcdefg" {
+		t.Errorf("Expected 'abcdefg', got '%s' (%v)", plain, err)
+	}
+
+	// 错误的主口令
+	SetPassphrase("wrong")
+	if _, err := DecryptPassword(enc); err != ErrWrongPassphrase {
+		t.Errorf("Expected ErrWrongPassphrase, got %v", err)
+	}
+}
+
+func TestWriteConfigEncrypted(t *testing.T) {
+	outputDir := "tmp"
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		t.Fatalf("Failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(outputDir)
+
+	SetPassphrase("master")
+	defer SetPassphrase("")
+
+	configPath := outputDir + "/test_config.yaml"
+	config := SSHConfig{
+		Host:          "test",
+		Hostname:      "test.com",
+		User:          "testuser",
+		Port:          22,
+		Password:      "testpassword",
+		LoginTimes:    0,
+		LastLoginTime: loginTime("2023-07-01T12:00:00"),
+	}
+
+	if err := WriteConfig(configPath, []SSHConfig{config}); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+
+	content, _ := os.ReadFile(configPath)
+	if strings.Contains(string(content), "testpassword") {
+		t.Fatalf("Password written in plain text:\n%s", content)
+	}
+
+	// 新进程读取时通过 PassphraseFunc 获取主口令
+	SetPassphrase("")
+	oldFunc := PassphraseFunc
+	PassphraseFunc = func() (string, error) { return "master", nil }
+	defer func() { PassphraseFunc = oldFunc }()
+
+	cfgs, err := ReadConfig(configPath)
+	if err != nil || cfgs == nil || len(*cfgs) == 0 || !(*cfgs)[0].Equals(&config) {
+		t.Errorf("Error reading encrypted config: %v", err)
+	}
+}
+
+func TestVaultMarker(t *testing.T) {
+	configPath := t.TempDir() + "/config_cache"
+	oldFunc, oldNewFunc := PassphraseFunc, NewPassphraseFunc
+	PassphraseFunc = func() (string, error) { return "", errors.New("expected confirmation") }
+	confirmed := 0
+	NewPassphraseFunc = func() (string, error) {
+		confirmed++
+		return "master", nil
+	}
+	defer func() { PassphraseFunc, NewPassphraseFunc = oldFunc, oldNewFunc }()
+	defer SetPassphrase("")
+
+	// 开启加密存储时还没有任何密码
+	SetPassphrase("master")
+	if err := WriteConfig(configPath, []SSHConfig{{Host: "node1", Hostname: "10.0.0.1", User: "root"}}); err != nil {
+		t.Fatalf("Error writing config: %v", err)
+	}
+
+	// 新进程读取后添加的密码仍然加密写入, 还没有密文时主口令需要确认
+	SetPassphrase("")
+	_, err := UpdateConfig(configPath, func(configs *[]SSHConfig) error {
+		if !VaultEnabled() {
+			t.Errorf("Expected vault to be enabled by the marker")
+		}
+		(*configs)[0].Password = "secret"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error updating config: %v", err)
+	}
+	if confirmed != 1 {
+		t.Errorf("Expected passphrase to be confirmed once, got %d", confirmed)
+	}
+	content, _ := os.ReadFile(configPath)
+	if !strings.Contains(string(content), vaultMarker+"\n") || strings.Contains(string(content), "secret") {
+		t.Errorf("Expected vault marker and encrypted password, got:\n%s", content)
+	}
+
+	// 没有开启加密存储时设置了 SSP_PASSPHRASE 也写入明文
+	SetPassphrase("")
+	t.Setenv(PassphraseEnv, "master")
+	plainPath := t.TempDir() + "/config_cache"
+	WriteConfig(plainPath, []SSHConfig{{Host: "node1", Hostname: "10.0.0.1", User: "root"}})
+	UpdateConfig(plainPath, func(configs *[]SSHConfig) error {
+		(*configs)[0].Password = "secret"
+		return nil
+	})
+	content, _ = os.ReadFile(plainPath)
+	if strings.Contains(string(content), vaultMarker) || !strings.Contains(string(content), "#ssp:Password secret") {
+		t.Errorf("Expected plain text cache, got:\n%s", content)
+	}
+
+	// 已有密文时用它校验输入的主口令
+	SetPassphrase("master")
+	enc, _ := EncryptPassword("secret")
+	SetPassphrase("")
+	PassphraseFunc = func() (string, error) { return "wrong", nil }
+	if err := ensureWritePassphrase([]SSHConfig{{Host: "node1", Password: enc}}); !errors.Is(err, ErrWrongPassphrase) || passphrase != "" {
+		t.Errorf("Expected wrong passphrase to be rejected, got %v", err)
+	}
+}
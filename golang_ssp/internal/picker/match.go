@@ -0,0 +1,47 @@
+package picker
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match 模糊匹配, 查询按空格分成多个词, 每个词的字符都要按顺序出现在 text 中
+// 连续匹配、单词开头匹配和子串匹配得分更高; 查询包含大写字母时区分大小写
+func Match(query string, text string) (int, bool) {
+	if !strings.ContainsFunc(query, unicode.IsUpper) {
+		text = strings.ToLower(text)
+	}
+	total := 0
+	for _, term := range strings.Fields(query) {
+		score, ok := matchTerm([]rune(term), []rune(text))
+		if !ok {
+			return 0, false
+		}
+		total += score
+		if strings.Contains(text, term) {
+			total += 2 * len(term)
+		}
+	}
+	return total, true
+}
+
+func matchTerm(term []rune, text []rune) (int, bool) {
+	score := 0
+	last := -2
+	i := 0
+	for j := 0; j < len(text) && i < len(term); j++ {
+		if text[j] != term[i] {
+			continue
+		}
+		score++
+		if j == last+1 {
+			score += 3
+		}
+		if j == 0 || strings.ContainsRune(" .-_@", text[j-1]) {
+			score += 2
+		}
+		last = j
+		i++
+	}
+	return score, i == len(term)
+}
@@ -0,0 +1,349 @@
+package picker
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+
+	"golang.org/x/term"
+)
+
+// ErrCancelled 用户按 Esc 或 Ctrl-C 取消选择
+var ErrCancelled = errors.New("cancelled")
+
+// Pick 打开全屏的模糊查找, 在 Host、HostName、User 上匹配, 返回选中的主机
+func Pick(cfgs []config.SSHConfig) (*config.SSHConfig, error) {
+	if len(cfgs) == 0 {
+		return nil, errors.New("no cached hosts, login with ssp <host> first")
+	}
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil, errors.New("host picker needs a terminal, use ssp <host> or ssp <index>")
+	}
+	state, err := term.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer term.Restore(fd, state)
+
+	// 使用备用屏幕, 退出后恢复原来的终端内容
+	fmt.Fprint(os.Stdout, "\x1b[?1049h")
+	defer fmt.Fprint(os.Stdout, "\x1b[?1049l")
+
+	p := newPicker(cfgs)
+	index, err := p.run(os.Stdin, os.Stdout, func() (int, int) {
+		width, height, err := term.GetSize(int(os.Stdout.Fd()))
+		if err != nil {
+			return 80, 24
+		}
+		return width, height
+	})
+	if err != nil {
+		return nil, err
+	}
+	cfg := cfgs[index]
+	return &cfg, nil
+}
+
+type picker struct {
+	cfgs     []config.SSHConfig
+	query    []rune
+	matches  []int // 匹配的条目下标, 按得分排序
+	cursor   int   // 选中的条目在 matches 中的位置
+	offset   int   // 列表滚动位置
+	pageSize int
+}
+
+func newPicker(cfgs []config.SSHConfig) *picker {
+	p := &picker{cfgs: cfgs, pageSize: 10}
+	p.filter()
+	return p
+}
+
+// filter 按查询重新匹配, 得分相同时保持缓存中的 frecency 顺序
+func (p *picker) filter() {
+	query := string(p.query)
+	scores := map[int]int{}
+	p.matches = p.matches[:0]
+	for i := range p.cfgs {
+		c := &p.cfgs[i]
+		if score, ok := Match(query, c.Host+" "+c.User+"@"+c.Hostname); ok {
+			scores[i] = score
+			p.matches = append(p.matches, i)
+		}
+	}
+	sort.SliceStable(p.matches, func(a, b int) bool {
+		return scores[p.matches[a]] > scores[p.matches[b]]
+	})
+	p.cursor = 0
+	p.offset = 0
+}
+
+func (p *picker) move(delta int) {
+	p.cursor += delta
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// run 处理按键直到选中或取消, 返回选中条目的下标
+func (p *picker) run(in io.Reader, out io.Writer, size func() (int, int)) (int, error) {
+	reader := bufio.NewReader(in)
+	for {
+		width, height := size()
+		p.render(out, width, height)
+
+		k, ch, err := readKey(reader)
+		if err != nil {
+			return -1, ErrCancelled
+		}
+		switch k {
+		case keyEnter:
+			if len(p.matches) > 0 {
+				return p.matches[p.cursor], nil
+			}
+		case keyCancel:
+			return -1, ErrCancelled
+		case keyUp:
+			p.move(-1)
+		case keyDown:
+			p.move(1)
+		case keyPageUp:
+			p.move(-p.pageSize)
+		case keyPageDown:
+			p.move(p.pageSize)
+		case keyBackspace:
+			if len(p.query) > 0 {
+				p.query = p.query[:len(p.query)-1]
+				p.filter()
+			}
+		case keyClear:
+			p.query = nil
+			p.filter()
+		case keyRune:
+			p.query = append(p.query, ch)
+			p.filter()
+		}
+	}
+}
+
+type key int
+
+const (
+	keyUnknown key = iota
+	keyRune
+	keyEnter
+	keyCancel
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyBackspace
+	keyClear
+)
+
+// readKey 读取一个按键, 解析方向键等转义序列
+func readKey(r *bufio.Reader) (key, rune, error) {
+	ch, _, err := r.ReadRune()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	switch ch {
+	case '\r', '\n':
+		return keyEnter, 0, nil
+	case 0x03, 0x04: // Ctrl-C, Ctrl-D
+		return keyCancel, 0, nil
+	case 0x7f, 0x08:
+		return keyBackspace, 0, nil
+	case 0x15: // Ctrl-U
+		return keyClear, 0, nil
+	case 0x10: // Ctrl-P
+		return keyUp, 0, nil
+	case 0x0e: // Ctrl-N
+		return keyDown, 0, nil
+	case 0x1b:
+		// 单独的 Esc 不会紧跟其它字节
+		if r.Buffered() == 0 {
+			return keyCancel, 0, nil
+		}
+		return readEscape(r)
+	}
+	if unicode.IsPrint(ch) {
+		return keyRune, ch, nil
+	}
+	return keyUnknown, 0, nil
+}
+
+func readEscape(r *bufio.Reader) (key, rune, error) {
+	prefix, err := r.ReadByte()
+	if err != nil {
+		return keyUnknown, 0, err
+	}
+	if prefix != '[' && prefix != 'O' {
+		return keyUnknown, 0, nil
+	}
+	var seq []byte
+	for {
+		b, err := r.ReadByte()
+		if err != nil {
+			return keyUnknown, 0, err
+		}
+		seq = append(seq, b)
+		// CSI 序列以 0x40-0x7e 结束
+		if b >= 0x40 && b <= 0x7e {
+			break
+		}
+	}
+	switch string(seq) {
+	case "A":
+		return keyUp, 0, nil
+	case "B":
+		return keyDown, 0, nil
+	case "5~":
+		return keyPageUp, 0, nil
+	case "6~":
+		return keyPageDown, 0, nil
+	}
+	return keyUnknown, 0, nil
+}
+
+// render 输出一屏内容, 宽屏时预览在右侧, 否则在列表下方
+func (p *picker) render(w io.Writer, width, height int) {
+	var preview []string
+	if len(p.matches) > 0 {
+		preview = previewLines(&p.cfgs[p.matches[p.cursor]])
+	}
+
+	listWidth, previewWidth := width, 0
+	rows := height - 2
+	sideBySide := width >= 80
+	if sideBySide {
+		listWidth = width / 2
+		previewWidth = width - listWidth - 3
+	} else if rows-len(preview)-1 >= 5 {
+		rows -= len(preview) + 1
+	} else {
+		preview = nil
+	}
+	if rows < 1 {
+		rows = 1
+	}
+	p.pageSize = rows
+
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+
+	var lines []string
+	lines = append(lines, truncate("> "+string(p.query), width))
+	lines = append(lines, fmt.Sprintf("  \x1b[2m%d/%d\x1b[0m", len(p.matches), len(p.cfgs)))
+	for row := 0; row < rows; row++ {
+		item := ""
+		i := p.offset + row
+		if i < len(p.matches) {
+			c := &p.cfgs[p.matches[i]]
+			item = truncate(fmt.Sprintf("  %-20s %s@%s", c.Host, c.User, c.Hostname), listWidth)
+			if i == p.cursor {
+				item = "\x1b[7m" + item + strings.Repeat(" ", listWidth-len([]rune(item))) + "\x1b[0m"
+			}
+		}
+		if sideBySide {
+			item += strings.Repeat(" ", max(0, listWidth-visibleLen(item)))
+			if row < len(preview) {
+				item += " \x1b[2m│\x1b[0m " + truncate(preview[row], previewWidth)
+			} else {
+				item += " \x1b[2m│\x1b[0m"
+			}
+		}
+		lines = append(lines, item)
+	}
+	if !sideBySide && preview != nil {
+		lines = append(lines, "\x1b[2m"+strings.Repeat("─", width)+"\x1b[0m")
+		for _, l := range preview {
+			lines = append(lines, truncate(l, width))
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	b.WriteString(strings.Join(lines, "\x1b[K\r\n"))
+	b.WriteString("\x1b[K\x1b[J")
+	// 光标停在输入框末尾
+	fmt.Fprintf(&b, "\x1b[1;%dH", min(width, 3+len(p.query)))
+	io.WriteString(w, b.String())
+}
+
+// previewLines 选中主机的详细信息, 不显示密码
+func previewLines(c *config.SSHConfig) []string {
+	lines := []string{
+		fmt.Sprintf("%-10s %s", "Host", c.Host),
+		fmt.Sprintf("%-10s %s", "HostName", c.Hostname),
+		fmt.Sprintf("%-10s %s", "User", c.User),
+		fmt.Sprintf("%-10s %d", "Port", c.PortOrDefault()),
+	}
+	if c.ProxyJump != "" {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "ProxyJump", c.ProxyJump))
+	}
+	if c.IdentityFile != "" {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "Identity", c.IdentityFile))
+	}
+	if c.AuthMethods != "" {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "Auth", c.AuthMethods))
+	}
+	for _, f := range c.Forwards {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "Forward", f.Spec()))
+	}
+	lines = append(lines, fmt.Sprintf("%-10s %d", "Logins", c.LoginTimes))
+	if !c.LastLoginTime.IsZero() {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "Last", c.LastLoginTime.Local().Format("2006-01-02 15:04")))
+	}
+	if !c.LastCheck.Time.IsZero() {
+		lines = append(lines, fmt.Sprintf("%-10s %s %s", "Checked", c.LastCheck.Time.Local().Format("2006-01-02 15:04"), c.LastCheck.Status))
+	}
+	if c.HostKey != "" {
+		lines = append(lines, fmt.Sprintf("%-10s %s", "HostKey", c.HostKey))
+	}
+	return lines
+}
+
+func truncate(s string, width int) string {
+	r := []rune(s)
+	if width <= 0 {
+		return ""
+	}
+	if len(r) > width {
+		return string(r[:width])
+	}
+	return s
+}
+
+// visibleLen 去掉 ANSI 转义序列后的长度
+func visibleLen(s string) int {
+	n := 0
+	inEscape := false
+	for _, ch := range s {
+		switch {
+		case ch == 0x1b:
+			inEscape = true
+		case inEscape:
+			if ch >= 0x40 && ch <= 0x7e && ch != '[' {
+				inEscape = false
+			}
+		default:
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,86 @@
+package picker
+
+import (
+	"errors"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	testCases := []struct {
+		query string
+		text  string
+		ok    bool
+	}{
+		{"", "web1 root@10.0.0.1", true},
+		{"wb1", "web1 root@10.0.0.1", true},
+		{"root 10.0", "web1 root@10.0.0.1", true},
+		{"WEB", "web1 root@10.0.0.1", false},
+		{"db", "web1 root@10.0.0.1", false},
+	}
+	for _, tc := range testCases {
+		if _, ok := Match(tc.query, tc.text); ok != tc.ok {
+			t.Errorf("Match(%q, %q): expected %v", tc.query, tc.text, tc.ok)
+		}
+	}
+
+	// 连续匹配的得分高于分散匹配
+	a, _ := Match("db", "db1 root@10.0.0.5")
+	b, _ := Match("db", "dev-build root@10.0.0.6")
+	if a <= b {
+		t.Errorf("Expected contiguous match to score higher, got %d <= %d", a, b)
+	}
+}
+
+func TestPickerRun(t *testing.T) {
+	cfgs := []config.SSHConfig{
+		{Host: "web1", Hostname: "10.0.0.1", User: "deploy"},
+		{Host: "web2", Hostname: "10.0.0.2", User: "deploy"},
+		{Host: "db1", Hostname: "10.0.0.5", User: "root"},
+	}
+	size := func() (int, int) { return 100, 20 }
+
+	testCases := []struct {
+		input string
+		index int
+		err   error
+	}{
+		{"\r", 0, nil},
+		{"\x1b[B\x1b[B\r", 2, nil},
+		{"\x1b[B\x1b[A\r", 0, nil},
+		{"web\x0e\r", 1, nil},
+		{"root\r", 2, nil},
+		{"dbx\x7f\r", 2, nil},
+		{"xyz\x15\r", 0, nil},
+		{"web\x1b", -1, ErrCancelled},
+		{"\x03", -1, ErrCancelled},
+		{"", -1, ErrCancelled},
+	}
+	for _, tc := range testCases {
+		p := newPicker(cfgs)
+		index, err := p.run(strings.NewReader(tc.input), io.Discard, size)
+		if index != tc.index || !errors.Is(err, tc.err) {
+			t.Errorf("Input %q: expected %d %v, got %d %v", tc.input, tc.index, tc.err, index, err)
+		}
+	}
+}
+
+func TestRender(t *testing.T) {
+	cfgs := []config.SSHConfig{
+		{Host: "web1", Hostname: "10.0.0.1", User: "deploy", Password: "secret", ProxyJump: "bastion"},
+	}
+	for _, width := range []int{100, 60} {
+		var out strings.Builder
+		newPicker(cfgs).render(&out, width, 20)
+		for _, s := range []string{"1/1", "web1", "deploy@10.0.0.1", "ProxyJump  bastion"} {
+			if !strings.Contains(out.String(), s) {
+				t.Errorf("Width %d: expected output to contain %q", width, s)
+			}
+		}
+		if strings.Contains(out.String(), "secret") {
+			t.Errorf("Width %d: password shown in preview", width)
+		}
+	}
+}
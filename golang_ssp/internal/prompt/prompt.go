@@ -0,0 +1,121 @@
+package prompt
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// Prompter 读取交互输入: 终端上支持行编辑 (方向键、Ctrl-A/E/U 等), 密码不回显;
+// 标准输入不是终端时按行读取, 便于脚本通过管道提供输入
+type Prompter struct {
+	Retries int // 终端上输入不合法时最多重新输入的次数
+
+	in  *bufio.Reader
+	out io.Writer
+	fd  int
+	tty bool
+}
+
+// New 使用标准输入和标准输出
+func New() *Prompter {
+	return NewPrompter(os.Stdin, os.Stdout)
+}
+
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	p := &Prompter{Retries: 3, in: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		p.fd, p.tty = int(f.Fd()), true
+	}
+	return p
+}
+
+// Interactive 输入是否来自终端
+func (p *Prompter) Interactive() bool {
+	return p.tty
+}
+
+// Line 读取一行, 输入为空时使用 def; validate 不为 nil 时校验输入,
+// 终端上校验失败会提示错误并重新输入, 非终端时直接返回错误
+func (p *Prompter) Line(label, def string, validate func(string) error) (string, error) {
+	return p.ask(label, def, false, validate)
+}
+
+// Secret 读取密码等不回显的输入, 不去掉首尾空格
+func (p *Prompter) Secret(label string, validate func(string) error) (string, error) {
+	return p.ask(label, "", true, validate)
+}
+
+// Retry 终端上提示 err 并返回 true 表示可以重新输入, 用于跨多个输入的校验
+func (p *Prompter) Retry(attempt int, err error) bool {
+	if !p.tty || attempt > p.Retries {
+		return false
+	}
+	fmt.Fprintf(p.out, "%v, please try again\n", err)
+	return true
+}
+
+func (p *Prompter) ask(label, def string, secret bool, validate func(string) error) (string, error) {
+	prompt := label
+	if def != "" {
+		prompt += " [" + def + "]"
+	}
+	prompt += ": "
+
+	for attempt := 1; ; attempt++ {
+		value, err := p.read(prompt, secret)
+		if err != nil {
+			return "", err
+		}
+		if !secret {
+			value = strings.TrimSpace(value)
+		}
+		if value == "" {
+			value = def
+		}
+		if validate == nil {
+			return value, nil
+		}
+		err = validate(value)
+		if err == nil {
+			return value, nil
+		}
+		if !p.Retry(attempt, err) {
+			return "", err
+		}
+	}
+}
+
+// read 终端上 Ctrl-C 和 Ctrl-D 返回 io.EOF 表示取消; 非终端读到结尾时按空输入处理, 使用默认值
+func (p *Prompter) read(prompt string, secret bool) (string, error) {
+	if !p.tty {
+		fmt.Fprint(p.out, prompt)
+		line, err := p.in.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if err == io.EOF {
+			fmt.Fprintln(p.out)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	state, err := term.MakeRaw(p.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(p.fd, state)
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{os.Stdin, p.out}, prompt)
+	if secret {
+		return t.ReadPassword(prompt)
+	}
+	return t.ReadLine()
+}
@@ -0,0 +1,49 @@
+package prompt
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrompter(t *testing.T) {
+	var out bytes.Buffer
+	p := NewPrompter(strings.NewReader("  node1  \n\n secret \n"), &out)
+	if p.Interactive() {
+		t.Fatalf("Expected non-interactive prompter for a reader")
+	}
+
+	if host, err := p.Line("Host", "", nil); err != nil || host != "node1" {
+		t.Errorf("Expected node1, got %q (%v)", host, err)
+	}
+	if user, err := p.Line("User", "root", nil); err != nil || user != "root" {
+		t.Errorf("Expected default root, got %q (%v)", user, err)
+	}
+	if password, err := p.Secret("Password", nil); err != nil || password != " secret " {
+		t.Errorf("Expected password with spaces kept, got %q (%v)", password, err)
+	}
+	// 输入结束后按空输入处理
+	if port, err := p.Line("Port", "22", nil); err != nil || port != "22" {
+		t.Errorf("Expected default 22 at EOF, got %q (%v)", port, err)
+	}
+	if !strings.Contains(out.String(), "User [root]: ") {
+		t.Errorf("Expected default in prompt, got %q", out.String())
+	}
+
+	// 非终端时校验失败直接返回错误, 不再读取下一行
+	p = NewPrompter(strings.NewReader("abc\n22\n"), &out)
+	invalid := errors.New("invalid port")
+	_, err := p.Line("Port", "22", func(v string) error {
+		if v != "22" {
+			return invalid
+		}
+		return nil
+	})
+	if !errors.Is(err, invalid) {
+		t.Errorf("Expected validation error, got %v", err)
+	}
+	if p.Retry(1, invalid) {
+		t.Errorf("Expected no retry without a terminal")
+	}
+}
@@ -0,0 +1,188 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// DefaultDir 录像保存目录, 可以通过 SSP_RECORD_DIR 修改
+const DefaultDir = "~/.ssh/ssp_recordings"
+
+// Env 设置为 1/true/on 时录制所有主机的交互式登录
+const Env = "SSP_RECORD"
+
+// StdinEnv 设置为 1/true/on 时同时录制键盘输入, 与 asciinema --stdin 相同默认关闭,
+// 因为输入中可能包含在远程提示符下输入的密码
+const StdinEnv = "SSP_RECORD_STDIN"
+
+// Enabled 主机开启了录像, 或者通过环境变量全局开启
+func Enabled(cfg *config.SSHConfig) bool {
+	return cfg.Record || envEnabled(Env)
+}
+
+func envEnabled(name string) bool {
+	switch strings.ToLower(os.Getenv(name)) {
+	case "1", "true", "on", "yes":
+		return true
+	}
+	return false
+}
+
+// Dir 返回录像目录
+func Dir() string {
+	if dir := os.Getenv("SSP_RECORD_DIR"); dir != "" {
+		return config.AbsPath(dir)
+	}
+	return config.AbsPath(DefaultDir)
+}
+
+// Header asciicast v2 的文件头, SSP 为 ssp 附加的主机信息, 播放器会忽略
+type Header struct {
+	Version   int               `json:"version"`
+	Width     int               `json:"width"`
+	Height    int               `json:"height"`
+	Timestamp int64             `json:"timestamp"`
+	Title     string            `json:"title,omitempty"`
+	Env       map[string]string `json:"env,omitempty"`
+	SSP       *HostInfo         `json:"ssp,omitempty"`
+}
+
+type HostInfo struct {
+	Host     string `json:"host"`
+	Hostname string `json:"hostname"`
+	User     string `json:"user"`
+	Port     uint16 `json:"port"`
+}
+
+// Recorder 把终端的输出、输入和窗口大小变化写成 asciicast v2 文件
+type Recorder struct {
+	Path  string
+	Stdin bool // 是否录制键盘输入, 由 SSP_RECORD_STDIN 开启
+
+	mu     sync.Mutex
+	file   *os.File
+	w      *bufio.Writer
+	cfg    *config.SSHConfig
+	start  time.Time
+	closed bool
+}
+
+// New 在 dir 下创建 <host>-<时间>.cast, 文件只有当前用户可读
+func New(dir string, cfg *config.SSHConfig) (*Recorder, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	name := fmt.Sprintf("%s-%s.cast", safeName(cfg.Host), time.Now().Format("20060102-150405"))
+	path := filepath.Join(dir, name)
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, err
+	}
+	return &Recorder{Path: path, Stdin: envEnabled(StdinEnv), file: file, w: bufio.NewWriter(file), cfg: cfg}, nil
+}
+
+func safeName(host string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '/' || r == os.PathSeparator || r == ' ' {
+			return '_'
+		}
+		return r
+	}, host)
+}
+
+// Start 写入文件头, 之后的事件时间相对于此刻
+func (r *Recorder) Start(width, height int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.start = time.Now()
+	header := Header{
+		Version:   2,
+		Width:     width,
+		Height:    height,
+		Timestamp: r.start.Unix(),
+		Title:     fmt.Sprintf("%s@%s (%s)", r.cfg.User, r.cfg.Hostname, r.cfg.Host),
+		Env:       map[string]string{"TERM": os.Getenv("TERM"), "SHELL": os.Getenv("SHELL")},
+		SSP:       &HostInfo{Host: r.cfg.Host, Hostname: r.cfg.Hostname, User: r.cfg.User, Port: r.cfg.PortOrDefault()},
+	}
+	data, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	r.w.Write(append(data, '\n'))
+	return r.w.Flush()
+}
+
+// Output 返回记录输出 ("o" 事件) 的 Writer
+func (r *Recorder) Output() io.Writer {
+	return &eventWriter{r: r, kind: "o"}
+}
+
+// Input 返回记录输入 ("i" 事件) 的 Writer
+func (r *Recorder) Input() io.Writer {
+	return &eventWriter{r: r, kind: "i"}
+}
+
+// Resize 记录窗口大小变化 ("r" 事件)
+func (r *Recorder) Resize(width, height int) {
+	r.event("r", fmt.Sprintf("%dx%d", width, height))
+}
+
+func (r *Recorder) event(kind string, data string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed || r.start.IsZero() {
+		return
+	}
+	line, _ := json.Marshal([]any{time.Since(r.start).Seconds(), kind, data})
+	r.w.Write(append(line, '\n'))
+	// 每个事件都写入文件, 连接异常断开时也能保留录像
+	r.w.Flush()
+}
+
+func (r *Recorder) Close() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	if err := r.w.Flush(); err != nil {
+		r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// eventWriter 按 UTF-8 字符边界切分, 被拆开的多字节字符留到下一次写入
+type eventWriter struct {
+	r    *Recorder
+	kind string
+	tail []byte
+}
+
+func (w *eventWriter) Write(p []byte) (int, error) {
+	data := append(w.tail, p...)
+	cut := len(data)
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				cut = i
+			}
+			break
+		}
+	}
+	w.tail = append([]byte(nil), data[cut:]...)
+	if cut > 0 {
+		w.r.event(w.kind, string(data[:cut]))
+	}
+	return len(p), nil
+}
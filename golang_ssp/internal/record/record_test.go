@@ -0,0 +1,84 @@
+package record
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"golang_ssp/golang_ssp/internal/config"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	cfg := &config.SSHConfig{Host: "prod/db1", Hostname: "10.0.0.5", User: "root"}
+	rec, err := New(t.TempDir(), cfg)
+	if err != nil {
+		t.Fatalf("Failed to create recorder: %v", err)
+	}
+	if !strings.HasSuffix(rec.Path, ".cast") || strings.Contains(rec.Path, "prod/db1") {
+		t.Errorf("Unexpected recording path %s", rec.Path)
+	}
+
+	rec.Start(120, 40)
+	rec.Input().Write([]byte("ls\r"))
+	out := rec.Output()
+	// 中文被拆成两次写入
+	hello := []byte("你好\r\n")
+	out.Write(hello[:4])
+	out.Write(hello[4:])
+	rec.Resize(100, 30)
+	rec.Close()
+	out.Write([]byte("after close"))
+
+	info, _ := os.Stat(rec.Path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected mode 0600, got %v", info.Mode().Perm())
+	}
+
+	file, _ := os.Open(rec.Path)
+	defer file.Close()
+	scanner := bufio.NewScanner(file)
+	scanner.Scan()
+	var header Header
+	json.Unmarshal(scanner.Bytes(), &header)
+	if header.Version != 2 || header.Width != 120 || header.Height != 40 || header.SSP.Hostname != "10.0.0.5" {
+		t.Errorf("Unexpected header %+v", header)
+	}
+	var kinds, data []string
+	for scanner.Scan() {
+		var event []any
+		json.Unmarshal(scanner.Bytes(), &event)
+		kinds = append(kinds, event[1].(string))
+		data = append(data, event[2].(string))
+	}
+	if strings.Join(kinds, ",") != "i,o,o,r" || data[1]+data[2] != "你好\r\n" || data[3] != "100x30" {
+		t.Errorf("Unexpected events %v %q", kinds, data)
+	}
+
+	// 播放: 倍速和最长等待
+	var waits []time.Duration
+	sleep = func(d time.Duration) { waits = append(waits, d) }
+	defer func() { sleep = time.Sleep }()
+
+	cast := `{"version":2,"width":80,"height":24,"timestamp":1688212800}
+[1.0,"o","hello "]
+[1.5,"i","x"]
+[11.0,"o","world"]
+`
+	var buf bytes.Buffer
+	if _, err := Replay(strings.NewReader(cast), &buf, 2, 3*time.Second); err != nil {
+		t.Fatalf("Failed to replay: %v", err)
+	}
+	if buf.String() != "hello world" {
+		t.Errorf("Unexpected replay output %q", buf.String())
+	}
+	if len(waits) != 2 || waits[0] != 500*time.Millisecond || waits[1] != 3*time.Second {
+		t.Errorf("Unexpected waits %v", waits)
+	}
+
+	if _, err := Replay(strings.NewReader(`{"version":1}`), &buf, 1, 0); err == nil {
+		t.Errorf("Expected error for asciicast v1")
+	}
+}
@@ -0,0 +1,66 @@
+package record
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// sleep 测试时替换, 避免真正等待
+var sleep = time.Sleep
+
+// Replay 播放 asciicast v2 录像, 只输出 "o" 事件; speed 为播放倍速, maxIdle 大于 0 时限制两次输出之间的最长等待
+func Replay(r io.Reader, out io.Writer, speed float64, maxIdle time.Duration) (*Header, error) {
+	if speed <= 0 {
+		return nil, fmt.Errorf("invalid speed %v", speed)
+	}
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("empty recording")
+	}
+	var header Header
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("invalid asciicast header: %w", err)
+	}
+	if header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d, expected 2", header.Version)
+	}
+
+	last := 0.0
+	line := 1
+	for scanner.Scan() {
+		line++
+		var event []json.RawMessage
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil || len(event) != 3 {
+			return &header, fmt.Errorf("line %d: invalid event", line)
+		}
+		var at float64
+		var kind, data string
+		if json.Unmarshal(event[0], &at) != nil || json.Unmarshal(event[1], &kind) != nil || json.Unmarshal(event[2], &data) != nil {
+			return &header, fmt.Errorf("line %d: invalid event", line)
+		}
+		if kind != "o" {
+			continue
+		}
+
+		wait := time.Duration((at - last) / speed * float64(time.Second))
+		if maxIdle > 0 && wait > maxIdle {
+			wait = maxIdle
+		}
+		if wait > 0 {
+			sleep(wait)
+		}
+		last = at
+		if _, err := io.WriteString(out, data); err != nil {
+			return &header, err
+		}
+	}
+	return &header, scanner.Err()
+}
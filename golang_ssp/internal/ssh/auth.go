@@ -0,0 +1,148 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/internal/prompt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// AuthError 目标主机拒绝了缓存的认证信息, 或者没有可用的认证方式, 与网络错误区分
+type AuthError struct {
+	Host string
+	User string
+	Err  error
+
+	key string // 认证失败的条目, 区分目标主机和跳板机
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("authentication failed for %s@%s: %v", e.User, e.Host, e.Err)
+}
+
+func (e *AuthError) Unwrap() error {
+	return e.Err
+}
+
+var errNoAuthMethod = errors.New("no usable auth method")
+
+// isAuthFailure x/crypto/ssh 的认证失败没有单独的错误类型, 只能按错误信息判断
+func isAuthFailure(err error) bool {
+	return errors.Is(err, errNoAuthMethod) || strings.Contains(err.Error(), "unable to authenticate")
+}
+
+// authMethods 按配置的顺序构造认证方式, 不可用的方式 (没有 agent、没有私钥、没有密码) 直接跳过
+// 返回的 cleanup 用于在握手结束后关闭 agent 连接
+func authMethods(cfg *config.SSHConfig) ([]gossh.AuthMethod, func(), error) {
+	cleanup := func() {}
+	methods, err := config.ParseAuthMethods(cfg.AuthMethods)
+	if err != nil {
+		return nil, cleanup, err
+	}
+
+	var auths []gossh.AuthMethod
+	for _, method := range methods {
+		switch method {
+		case config.AuthAgent:
+			socket := os.Getenv("SSH_AUTH_SOCK")
+			if socket == "" {
+				continue
+			}
+			conn, err := net.Dial("unix", socket)
+			if err != nil {
+				fmt.Printf("Warning: connect to ssh-agent failed: %v\n", err)
+				continue
+			}
+			cleanup = func() { conn.Close() }
+			auths = append(auths, gossh.PublicKeysCallback(agent.NewClient(conn).Signers))
+
+		case config.AuthKey:
+			if cfg.IdentityFile == "" {
+				continue
+			}
+			signer, err := loadIdentityFile(cfg.IdentityFile)
+			if err != nil {
+				return nil, cleanup, err
+			}
+			auths = append(auths, gossh.PublicKeys(signer))
+
+		case config.AuthPassword:
+			if cfg.Password == "" {
+				continue
+			}
+			auths = append(auths, gossh.Password(cfg.Password))
+
+		case config.AuthKeyboardInteractive:
+			if cfg.Password == "" {
+				continue
+			}
+			// 部分服务器只开启了 keyboard-interactive, 同样使用缓存的密码回答
+			password := cfg.Password
+			auths = append(auths, gossh.KeyboardInteractive(func(name, instruction string, questions []string, echos []bool) ([]string, error) {
+				answers := make([]string, len(questions))
+				for i := range questions {
+					answers[i] = password
+				}
+				return answers, nil
+			}))
+		}
+	}
+
+	if len(auths) == 0 {
+		return nil, cleanup, fmt.Errorf("%w for host %s (methods: %v)", errNoAuthMethod, cfg.Host, methods)
+	}
+	return auths, cleanup, nil
+}
+
+// signers 已经解析的私钥, 并发连接多个主机时同一个私钥只询问一次口令
+var (
+	signersMu sync.Mutex
+	signers   = map[string]gossh.Signer{}
+)
+
+// loadIdentityFile 读取私钥, 私钥有口令保护时从终端询问
+func loadIdentityFile(path string) (gossh.Signer, error) {
+	path = config.AbsPath(path)
+
+	signersMu.Lock()
+	defer signersMu.Unlock()
+	if signer, ok := signers[path]; ok {
+		return signer, nil
+	}
+	signer, err := parseIdentityFile(path)
+	if err != nil {
+		return nil, err
+	}
+	signers[path] = signer
+	return signer, nil
+}
+
+func parseIdentityFile(path string) (gossh.Signer, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read identity file: %w", err)
+	}
+
+	signer, err := gossh.ParsePrivateKey(pem)
+	var missing *gossh.PassphraseMissingError
+	if !errors.As(err, &missing) {
+		return signer, err
+	}
+
+	p := prompt.New()
+	if !p.Interactive() {
+		return nil, fmt.Errorf("identity file %s is protected by a passphrase", path)
+	}
+	passphrase, err := p.Secret(fmt.Sprintf("Enter passphrase for key '%s'", path), nil)
+	if err != nil {
+		return nil, err
+	}
+	return gossh.ParsePrivateKeyWithPassphrase(pem, []byte(passphrase))
+}
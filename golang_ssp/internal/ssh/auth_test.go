@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"golang_ssp/golang_ssp/internal/config"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	ssh3 "github.com/gliderlabs/ssh"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// startKeyServer 启动只允许公钥认证的测试服务器
+func startKeyServer(t *testing.T, allowed gossh.PublicKey) uint16 {
+	return startTestServer(t, func(s ssh3.Session) { s.Exit(0) }, func(srv *ssh3.Server) error {
+		srv.PasswordHandler = nil
+		srv.PublicKeyHandler = func(ctx ssh3.Context, key ssh3.PublicKey) bool {
+			return ssh3.KeysEqual(key, allowed)
+		}
+		return nil
+	})
+}
+
+func TestIdentityFileAuth(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	sshPub, _ := gossh.NewPublicKey(pub)
+	port := startKeyServer(t, sshPub)
+
+	block, err := gossh.MarshalPrivateKey(priv, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal private key: %v", err)
+	}
+	identity := filepath.Join(t.TempDir(), "id_ed25519")
+	os.WriteFile(identity, pem.EncodeToMemory(block), 0600)
+
+	t.Setenv("SSH_AUTH_SOCK", "")
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, IdentityFile: identity}
+	if !checkConnection(cfg, nil) {
+		t.Fatalf("Expected identity file auth to succeed")
+	}
+
+	// 只允许密码认证时没有可用的认证方式
+	cfg.AuthMethods = "password"
+	if checkConnection(cfg, nil) {
+		t.Fatalf("Expected password-only auth to fail")
+	}
+
+	// 不合法的认证方式返回错误
+	cfg.AuthMethods = "password,bogus"
+	if _, err := Dial(cfg, nil); err == nil {
+		t.Errorf("Expected error for invalid AuthMethods")
+	}
+}
+
+func TestAgentAuth(t *testing.T) {
+	pub, priv, _ := ed25519.GenerateKey(rand.Reader)
+	sshPub, _ := gossh.NewPublicKey(pub)
+	port := startKeyServer(t, sshPub)
+
+	// 启动 ssh-agent
+	keyring := agent.NewKeyring()
+	keyring.Add(agent.AddedKey{PrivateKey: priv})
+	socket := filepath.Join(t.TempDir(), "agent.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go agent.ServeAgent(keyring, conn)
+		}
+	}()
+
+	t.Setenv("SSH_AUTH_SOCK", socket)
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, AuthMethods: "agent"}
+	if !checkConnection(cfg, nil) {
+		t.Fatalf("Expected agent auth to succeed")
+	}
+}
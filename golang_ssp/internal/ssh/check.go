@@ -0,0 +1,270 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// 检查结果, 保存到缓存的 #ssp:LastCheck
+const (
+	CheckOK              = "ok"
+	CheckUnreachable     = "unreachable"
+	CheckBadBanner       = "bad-banner"
+	CheckAuthFailed      = "auth-failed"
+	CheckHostKeyMismatch = "hostkey-mismatch"
+	CheckTimeout         = "timeout"
+	CheckError           = "error"
+)
+
+// CheckOptions ssp check 的并发和超时设置
+type CheckOptions struct {
+	Workers int           // 同时检查的主机数
+	Timeout time.Duration // 每个主机的超时时间, 包括 TCP 连接、读取 banner 和认证
+}
+
+// CheckResult 一个主机的检查结果, 依次检查 TCP 连通性、SSH banner、认证和主机密钥
+type CheckResult struct {
+	Host    string
+	Address string
+	Status  string
+	Latency time.Duration // TCP 连接耗时, 配置了 ProxyJump 时为整个连接的耗时
+	Banner  string
+	Auth    string // ok, failed 或 -
+	HostKey string // match, new, mismatch 或 -
+	Err     error
+	Time    time.Time
+
+	fingerprint string // 首次连接时记录的主机密钥
+	key         string // 条目的唯一标识, 保存结果时使用
+}
+
+func (r *CheckResult) OK() bool {
+	return r.Status == CheckOK
+}
+
+// Check 并发检查多个主机, 结果与 targets 顺序一致, 检查结果和首次记录的主机密钥保存到缓存
+func Check(targets []config.SSHConfig, cfgs *[]config.SSHConfig, configPath string, opts CheckOptions) []CheckResult {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = dialTimeout
+	}
+
+	results := make([]CheckResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i] = checkHost(targets[i], *cfgs, timeout)
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	saveCheckResults(cfgs, configPath, results)
+	return results
+}
+
+// checkHost cfg 和 cfgs 是副本, 多个主机同时检查互不影响
+func checkHost(cfg config.SSHConfig, cfgs []config.SSHConfig, timeout time.Duration) CheckResult {
+	r := CheckResult{Host: cfg.Host, Address: cfg.Address(), Auth: "-", HostKey: "-", Time: time.Now().UTC(), key: cfg.Key()}
+	deadline := time.Now().Add(timeout)
+
+	// 通过跳板机连接的主机无法直接探测端口, 只检查整个连接
+	if cfg.ProxyJump == "" {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", r.Address, timeout)
+		if err != nil {
+			r.Status, r.Err = CheckUnreachable, err
+			if isTimeout(err) {
+				r.Status = CheckTimeout
+			}
+			return r
+		}
+		r.Latency = time.Since(start)
+
+		conn.SetDeadline(deadline)
+		r.Banner, err = readBanner(conn)
+		conn.Close()
+		if err != nil {
+			r.Status, r.Err = CheckBadBanner, err
+			if isTimeout(err) {
+				r.Status = CheckTimeout
+			}
+			return r
+		}
+	}
+
+	pinned := cfg.HostKey
+	cfgs = append([]config.SSHConfig(nil), cfgs...)
+	start := time.Now()
+	client, err := dialTimeoutCheck(&cfg, &cfgs, time.Until(deadline))
+	if cfg.ProxyJump != "" {
+		r.Latency = time.Since(start)
+	}
+	var mismatch *HostKeyMismatchError
+	switch {
+	case err == nil:
+		client.Close()
+		r.Status, r.Auth = CheckOK, "ok"
+	case errors.As(err, &mismatch):
+		r.Status, r.Err = CheckHostKeyMismatch, err
+		// 跳板机的密钥变化时还没有连到目标主机, 目标主机的密钥未校验
+		if mismatch.Host == cfg.Host && mismatch.Address == cfg.Address() {
+			r.HostKey = "mismatch"
+		}
+		return r
+	case isAuthError(err):
+		r.Status, r.Auth, r.Err = CheckAuthFailed, "failed", err
+	case isTimeout(err):
+		r.Status, r.Err = CheckTimeout, err
+	default:
+		r.Status, r.Err = CheckError, err
+	}
+
+	// 认证在密钥交换之后, 只要走到认证这一步主机密钥就已经校验过了
+	if r.Status == CheckOK || r.Status == CheckAuthFailed {
+		r.HostKey = "match"
+		if pinned == "" {
+			r.HostKey = "new"
+			if r.Status == CheckOK {
+				r.fingerprint = cfg.HostKey
+			}
+		}
+	}
+	return r
+}
+
+// readBanner 读取服务器的版本标识, 如 SSH-2.0-OpenSSH_9.6, 之前可能有其他文本行
+func readBanner(conn net.Conn) (string, error) {
+	reader := bufio.NewReader(io.LimitReader(conn, 8192))
+	for {
+		line, err := reader.ReadString('\n')
+		line = strings.TrimRight(line, "\r\n")
+		if strings.HasPrefix(line, "SSH-") {
+			return line, nil
+		}
+		if err != nil {
+			if err == io.EOF {
+				return "", fmt.Errorf("no ssh banner received")
+			}
+			return "", err
+		}
+	}
+}
+
+// dialTimeoutCheck 超时后关闭未完成的连接, 认证卡住时也能返回
+func dialTimeoutCheck(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, timeout time.Duration) (*gossh.Client, error) {
+	type result struct {
+		client *gossh.Client
+		err    error
+	}
+	done := make(chan result, 1)
+	go func() {
+		client, err := Dial(cfg, cfgs)
+		done <- result{client, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.client, r.err
+	case <-timer.C:
+		go func() {
+			if r := <-done; r.client != nil {
+				r.client.Close()
+			}
+		}()
+		return nil, fmt.Errorf("timeout after %s", timeout.Round(time.Millisecond))
+	}
+}
+
+func isAuthError(err error) bool {
+	var authErr *AuthError
+	return errors.As(err, &authErr)
+}
+
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return (errors.As(err, &netErr) && netErr.Timeout()) || strings.HasPrefix(err.Error(), "timeout after")
+}
+
+// saveCheckResults 保存每个主机的检查结果和首次记录的主机密钥
+func saveCheckResults(cfgs *[]config.SSHConfig, configPath string, results []CheckResult) {
+	if configPath == "" || len(results) == 0 {
+		return
+	}
+	byKey := map[string]*CheckResult{}
+	for i := range results {
+		byKey[results[i].key] = &results[i]
+	}
+	latest, err := config.UpdateConfig(configPath, func(latest *[]config.SSHConfig) error {
+		for i := range *latest {
+			r, ok := byKey[(*latest)[i].Key()]
+			if !ok {
+				continue
+			}
+			(*latest)[i].LastCheck = config.CheckState{Time: r.Time.Truncate(time.Second), Status: r.Status, Latency: r.Latency.Round(time.Millisecond)}
+			if r.fingerprint != "" && (*latest)[i].HostKey == "" {
+				(*latest)[i].HostKey = r.fingerprint
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error writing config:", err)
+		return
+	}
+	*cfgs = *latest
+}
+
+// PrintCheckSummary 输出检查结果表格, 返回不正常的主机数
+func PrintCheckSummary(w io.Writer, results []CheckResult) int {
+	hostWidth, addrWidth := len("HOST"), len("ADDRESS")
+	for _, r := range results {
+		hostWidth = max(hostWidth, len(r.Host))
+		addrWidth = max(addrWidth, len(r.Address))
+	}
+
+	failed := 0
+	format := "%-*s  %-*s  %-16s  %-8s  %-6s  %-8s  %-24s"
+	fmt.Fprintf(w, format+"  %s\n", hostWidth, "HOST", addrWidth, "ADDRESS", "STATUS", "LATENCY", "AUTH", "HOSTKEY", "BANNER", "ERROR")
+	for _, r := range results {
+		if !r.OK() {
+			failed++
+		}
+		latency, banner, errMsg := "-", "-", ""
+		if r.Latency > 0 {
+			latency = r.Latency.Round(time.Millisecond).String()
+		}
+		if r.Banner != "" {
+			banner = r.Banner
+		}
+		if r.Err != nil {
+			errMsg = r.Err.Error()
+		}
+		fmt.Fprintf(w, format+"  %s\n", hostWidth, r.Host, addrWidth, r.Address, r.Status, latency, r.Auth, r.HostKey, banner, errMsg)
+	}
+	fmt.Fprintf(w, "%d hosts, %d ok, %d failed\n", len(results), len(results)-failed, failed)
+	return failed
+}
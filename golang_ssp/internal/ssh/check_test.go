@@ -0,0 +1,77 @@
+package ssh
+
+import (
+	"bytes"
+	"golang_ssp/golang_ssp/internal/config"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ssh3 "github.com/gliderlabs/ssh"
+)
+
+func TestCheck(t *testing.T) {
+	port := startTestServer(t, func(s ssh3.Session) {})
+
+	// 只接受连接不发送 banner 的端口
+	silent, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer silent.Close()
+	go func() {
+		for {
+			conn, err := silent.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closedPort := uint16(closed.Addr().(*net.TCPAddr).Port)
+	closed.Close()
+
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	cfgs := &[]config.SSHConfig{
+		{Host: "ok", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"},
+		{Host: "badpass", Hostname: "127.0.0.1", User: "test", Port: port, Password: "wrong", AuthMethods: "password"},
+		{Host: "changed", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234", HostKey: "SHA256:old"},
+		{Host: "down", Hostname: "127.0.0.1", User: "test", Port: closedPort, Password: "1234"},
+		{Host: "silent", Hostname: "127.0.0.1", User: "test", Port: uint16(silent.Addr().(*net.TCPAddr).Port), Password: "1234"},
+	}
+	config.WriteConfig(configPath, *cfgs)
+
+	results := Check(*cfgs, cfgs, configPath, CheckOptions{Workers: 5, Timeout: time.Second})
+	expected := []string{CheckOK, CheckAuthFailed, CheckHostKeyMismatch, CheckUnreachable, CheckTimeout}
+	for i, r := range results {
+		if r.Status != expected[i] {
+			t.Errorf("%s: expected %s, got %s (%v)", r.Host, expected[i], r.Status, r.Err)
+		}
+	}
+	if !strings.HasPrefix(results[0].Banner, "SSH-2.0-") || results[0].Auth != "ok" || results[0].HostKey != "new" || results[0].Latency <= 0 {
+		t.Errorf("Unexpected result %+v", results[0])
+	}
+	if results[1].Auth != "failed" || results[2].HostKey != "mismatch" {
+		t.Errorf("Unexpected results %+v %+v", results[1], results[2])
+	}
+
+	// 检查结果和首次记录的主机密钥保存到缓存
+	saved, _ := config.ReadConfig(configPath)
+	byHost := map[string]config.SSHConfig{}
+	for _, c := range *saved {
+		byHost[c.Host] = c
+	}
+	for i, r := range results {
+		if c := byHost[r.Host]; c.LastCheck.Status != expected[i] || c.LastCheck.Time.IsZero() {
+			t.Errorf("Expected last check %s saved for %s, got %+v", expected[i], r.Host, c.LastCheck)
+		}
+	}
+	if byHost["ok"].HostKey == "" || byHost["badpass"].HostKey != "" || byHost["changed"].HostKey != "SHA256:old" {
+		t.Errorf("Unexpected host keys %q %q %q", byHost["ok"].HostKey, byHost["badpass"].HostKey, byHost["changed"].HostKey)
+	}
+
+	var summary bytes.Buffer
+	if failed := PrintCheckSummary(&summary, results); failed != 4 {
+		t.Errorf("Expected 4 failed hosts, got %d:\n%s", failed, summary.String())
+	}
+}
@@ -0,0 +1,169 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/internal/record"
+	"io"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+const (
+	BackendNative  = "native"
+	BackendSSHPass = "sshpass"
+)
+
+// Backend 登录方式: native 使用内置 ssh 客户端, sshpass 调用 sshpass + ssh
+var Backend = BackendNative
+
+var dialTimeout = 10 * time.Second
+
+func clientConfig(cfg *config.SSHConfig, auths []gossh.AuthMethod) *gossh.ClientConfig {
+	return &gossh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auths,
+		HostKeyCallback: hostKeyCallback(cfg),
+		Timeout:         dialTimeout,
+	}
+}
+
+// Dial 使用缓存的认证信息 (agent、私钥、密码) 建立 ssh 连接
+// 配置了 ProxyJump 时依次通过 cfgs 中的跳板机连接, 每一跳使用各自缓存的认证信息
+func Dial(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (*gossh.Client, error) {
+	return dial(cfg, cfgs, map[string]bool{})
+}
+
+func dial(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, seen map[string]bool) (*gossh.Client, error) {
+	jump, err := dialJumpHost(cfg, cfgs, seen)
+	if err != nil {
+		return nil, err
+	}
+
+	auths, cleanup, err := authMethods(cfg)
+	defer cleanup()
+	if err == nil {
+		var client *gossh.Client
+		client, err = dialVia(jump, cfg.Address(), clientConfig(cfg, auths))
+		if err == nil {
+			return client, nil
+		}
+	}
+
+	if jump != nil {
+		jump.Close()
+	}
+	if isAuthFailure(err) {
+		err = &AuthError{Host: cfg.Host, User: cfg.User, Err: err, key: cfg.Key()}
+	}
+	return nil, err
+}
+
+// Shell 在远端打开交互式 shell, 返回远端的退出码; rec 不为空时录制本次会话
+func Shell(client *gossh.Client, rec *record.Recorder) (int, error) {
+	return runShell(client, os.Stdin, os.Stdout, os.Stderr, rec)
+}
+
+func runShell(client *gossh.Client, stdin io.Reader, stdout, stderr io.Writer, rec *record.Recorder) (int, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return 255, err
+	}
+	defer session.Close()
+
+	width, height := 80, 24
+
+	// 本地是终端时申请 PTY, 并切换到 raw 模式
+	if f, ok := stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fd := int(f.Fd())
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return 255, err
+		}
+		defer term.Restore(fd, state)
+
+		if w, h, err := term.GetSize(fd); err == nil {
+			width, height = w, h
+		}
+		termType := os.Getenv("TERM")
+		if termType == "" {
+			termType = "xterm-256color"
+		}
+		modes := gossh.TerminalModes{
+			gossh.ECHO:          1,
+			gossh.TTY_OP_ISPEED: 14400,
+			gossh.TTY_OP_OSPEED: 14400,
+		}
+		if err := session.RequestPty(termType, height, width, modes); err != nil {
+			return 255, err
+		}
+
+		done := make(chan struct{})
+		defer close(done)
+		go watchWindowSize(fd, session, rec, done)
+	}
+
+	if rec != nil {
+		if err := rec.Start(width, height); err != nil {
+			return 255, err
+		}
+		if rec.Stdin {
+			stdin = io.TeeReader(stdin, rec.Input())
+		}
+		stdout = io.MultiWriter(stdout, rec.Output())
+		stderr = io.MultiWriter(stderr, rec.Output())
+	}
+
+	session.Stdin = stdin
+	session.Stdout = stdout
+	session.Stderr = stderr
+
+	if err := session.Shell(); err != nil {
+		return 255, err
+	}
+	return exitCode(session.Wait())
+}
+
+// watchWindowSize 将本地终端的 SIGWINCH 转发给远端
+func watchWindowSize(fd int, session *gossh.Session, rec *record.Recorder, done <-chan struct{}) {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGWINCH)
+	defer signal.Stop(sigs)
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-sigs:
+			width, height, err := term.GetSize(fd)
+			if err != nil {
+				continue
+			}
+			session.WindowChange(height, width)
+			if rec != nil {
+				rec.Resize(width, height)
+			}
+		}
+	}
+}
+
+func exitCode(err error) (int, error) {
+	if err == nil {
+		return 0, nil
+	}
+	var exitErr *gossh.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitStatus(), nil
+	}
+	var missingErr *gossh.ExitMissingError
+	if errors.As(err, &missingErr) {
+		return 255, nil
+	}
+	return 255, fmt.Errorf("session failed: %w", err)
+}
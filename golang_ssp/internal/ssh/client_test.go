@@ -0,0 +1,77 @@
+package ssh
+
+import (
+	"bytes"
+	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/internal/record"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	ssh3 "github.com/gliderlabs/ssh"
+)
+
+func TestShell(t *testing.T) {
+	// 回显 stdin 并以 3 退出
+	port := startTestServer(t, func(s ssh3.Session) {
+		io.Copy(s, s)
+		s.Exit(3)
+	})
+
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+	client, err := Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	var stdout bytes.Buffer
+	code, err := runShell(client, strings.NewReader("hello\n"), &stdout, io.Discard, nil)
+	if err != nil {
+		t.Fatalf("Failed to run shell: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("Expected exit code 3, got %d", code)
+	}
+	if stdout.String() != "hello\n" {
+		t.Errorf("Expected output 'hello\\n', got %q", stdout.String())
+	}
+}
+
+func TestShellRecording(t *testing.T) {
+	port := startTestServer(t, func(s ssh3.Session) {
+		io.Copy(s, s)
+		s.Exit(0)
+	})
+
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+	client, err := Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	// 默认不录制键盘输入
+	for _, stdin := range []string{"", "1"} {
+		t.Setenv(record.StdinEnv, stdin)
+		rec, err := record.New(t.TempDir(), cfg)
+		if err != nil {
+			t.Fatalf("Failed to create recorder: %v", err)
+		}
+		if _, err := runShell(client, strings.NewReader("whoami\n"), io.Discard, io.Discard, rec); err != nil {
+			t.Fatalf("Failed to run shell: %v", err)
+		}
+		rec.Close()
+
+		content, _ := os.ReadFile(rec.Path)
+		for _, s := range []string{`"version":2`, `"o","whoami\n"`} {
+			if !strings.Contains(string(content), s) {
+				t.Errorf("Expected recording to contain %s, got:\n%s", s, content)
+			}
+		}
+		if recorded := strings.Contains(string(content), `"i","whoami\n"`); recorded != (stdin != "") {
+			t.Errorf("%s=%q: expected input recorded %v, got:\n%s", record.StdinEnv, stdin, stdin != "", content)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/audit"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/pkg/sftp"
+)
+
+// CopyOptions ssp cp 的选项, 与 scp 的 -r、-p、-q 对应
+type CopyOptions struct {
+	Recursive bool
+	Preserve  bool // 保留权限和修改时间
+	Quiet     bool // 不显示进度
+}
+
+// SplitRemotePath 按 scp 的规则解析 [user@]host:path, 冒号出现在第一个 / 之前才是远程路径
+func SplitRemotePath(arg string) (host string, p string, ok bool) {
+	i := strings.Index(arg, ":")
+	if i <= 0 {
+		return "", arg, false
+	}
+	if slash := strings.Index(arg, "/"); slash >= 0 && slash < i {
+		return "", arg, false
+	}
+	return arg[:i], arg[i+1:], true
+}
+
+// Copy 通过 sftp 在本地和缓存的主机之间复制文件, upload 为 true 时 sources 是本地路径, target 是远程路径
+// 远程源路径支持通配符, 如 /var/log/*.log
+func Copy(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string, sources []string, target string, upload bool, opts CopyOptions) error {
+	entry := audit.Begin(cfg, "cp")
+	client, err := Dial(cfg, cfgs)
+	if err != nil {
+		entry.Fail(err)
+		var mismatch *HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			fmt.Print(mismatch.Warning())
+		}
+		return err
+	}
+	defer client.Close()
+	if cfg.HostKey != "" {
+		savePinnedHostKeys(cfgs, configPath, map[string]string{cfg.Key(): cfg.HostKey})
+	}
+
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		err = fmt.Errorf("open sftp: %w", err)
+		entry.Finish(1, err)
+		return err
+	}
+	defer sc.Close()
+
+	c := &copier{client: sc, opts: opts, out: os.Stdout}
+	if opts.Quiet {
+		c.out = io.Discard
+	}
+	if upload {
+		err = c.uploadAll(sources, target)
+	} else {
+		err = c.downloadAll(sources, target)
+	}
+	if err != nil {
+		entry.Finish(1, err)
+	} else {
+		entry.Finish(0, nil)
+	}
+	return err
+}
+
+type copier struct {
+	client *sftp.Client
+	opts   CopyOptions
+	out    io.Writer
+}
+
+// uploadAll 目标是已存在的目录时复制到目录下, 多个源时目标必须是目录
+func (c *copier) uploadAll(sources []string, target string) error {
+	if target == "" {
+		target = "."
+	}
+	targetIsDir := false
+	if info, err := c.client.Stat(target); err == nil && info.IsDir() {
+		targetIsDir = true
+	}
+	if len(sources) > 1 && !targetIsDir {
+		return fmt.Errorf("target %s is not a directory", target)
+	}
+
+	failed := 0
+	for _, src := range sources {
+		dst := target
+		if targetIsDir {
+			dst = path.Join(target, filepath.Base(src))
+		}
+		if err := c.upload(src, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "ssp cp: %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources failed", failed, len(sources))
+	}
+	return nil
+}
+
+func (c *copier) upload(local, remote string) error {
+	info, err := os.Stat(local)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return c.uploadFile(local, remote, info)
+	}
+	if !c.opts.Recursive {
+		return fmt.Errorf("%s is a directory (use -r)", local)
+	}
+
+	if stat, err := c.client.Stat(remote); err != nil {
+		if err := c.client.Mkdir(remote); err != nil {
+			return fmt.Errorf("mkdir %s: %w", remote, err)
+		}
+	} else if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", remote)
+	}
+
+	entries, err := os.ReadDir(local)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.upload(filepath.Join(local, entry.Name()), path.Join(remote, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return c.preserveRemote(remote, info, info.ModTime())
+}
+
+func (c *copier) uploadFile(local, remote string, info os.FileInfo) error {
+	src, err := os.Open(local)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := c.client.OpenFile(remote, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return fmt.Errorf("open %s: %w", remote, err)
+	}
+	defer dst.Close()
+
+	bar := newProgress(filepath.Base(local), info.Size(), c.out)
+	if _, err := io.Copy(io.MultiWriter(dst, bar), src); err != nil {
+		return fmt.Errorf("upload %s: %w", local, err)
+	}
+	bar.Done()
+	if err := dst.Chmod(info.Mode().Perm()); err != nil {
+		return err
+	}
+	return c.preserveRemote(remote, info, info.ModTime())
+}
+
+func (c *copier) preserveRemote(remote string, info os.FileInfo, atime time.Time) error {
+	if !c.opts.Preserve {
+		return nil
+	}
+	if err := c.client.Chmod(remote, info.Mode().Perm()); err != nil {
+		return err
+	}
+	return c.client.Chtimes(remote, atime, info.ModTime())
+}
+
+// downloadAll 远程源路径包含通配符时先展开
+func (c *copier) downloadAll(sources []string, target string) error {
+	var expanded []string
+	for _, src := range sources {
+		if src == "" {
+			src = "."
+		}
+		if !strings.ContainsAny(src, "*?[") {
+			expanded = append(expanded, src)
+			continue
+		}
+		matches, err := c.client.Glob(src)
+		if err != nil {
+			return err
+		}
+		if len(matches) == 0 {
+			return fmt.Errorf("no such file: %s", src)
+		}
+		expanded = append(expanded, matches...)
+	}
+
+	targetIsDir := false
+	if info, err := os.Stat(target); err == nil && info.IsDir() {
+		targetIsDir = true
+	}
+	if len(expanded) > 1 && !targetIsDir {
+		return fmt.Errorf("target %s is not a directory", target)
+	}
+
+	failed := 0
+	for _, src := range expanded {
+		dst := target
+		if targetIsDir {
+			dst = filepath.Join(target, path.Base(src))
+		}
+		if err := c.download(src, dst); err != nil {
+			fmt.Fprintf(os.Stderr, "ssp cp: %v\n", err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d sources failed", failed, len(expanded))
+	}
+	return nil
+}
+
+func (c *copier) download(remote, local string) error {
+	info, err := c.client.Stat(remote)
+	if err != nil {
+		return fmt.Errorf("%s: %w", remote, err)
+	}
+	if !info.IsDir() {
+		return c.downloadFile(remote, local, info)
+	}
+	if !c.opts.Recursive {
+		return fmt.Errorf("%s is a directory (use -r)", remote)
+	}
+
+	if stat, err := os.Stat(local); err != nil {
+		if err := os.Mkdir(local, 0755); err != nil {
+			return err
+		}
+	} else if !stat.IsDir() {
+		return fmt.Errorf("%s is not a directory", local)
+	}
+
+	entries, err := c.client.ReadDir(remote)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if err := c.download(path.Join(remote, entry.Name()), filepath.Join(local, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return c.preserveLocal(local, info)
+}
+
+func (c *copier) downloadFile(remote, local string, info os.FileInfo) error {
+	src, err := c.client.Open(remote)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(local, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	bar := newProgress(path.Base(remote), info.Size(), c.out)
+	if _, err := io.Copy(io.MultiWriter(dst, bar), src); err != nil {
+		return fmt.Errorf("download %s: %w", remote, err)
+	}
+	bar.Done()
+	return c.preserveLocal(local, info)
+}
+
+func (c *copier) preserveLocal(local string, info os.FileInfo) error {
+	if !c.opts.Preserve {
+		return nil
+	}
+	if err := os.Chmod(local, info.Mode().Perm()); err != nil {
+		return err
+	}
+	atime := info.ModTime()
+	if stat, ok := info.Sys().(*sftp.FileStat); ok {
+		atime = time.Unix(int64(stat.Atime), 0)
+	}
+	return os.Chtimes(local, atime, info.ModTime())
+}
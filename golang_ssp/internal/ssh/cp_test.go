@@ -0,0 +1,105 @@
+package ssh
+
+import (
+	"golang_ssp/golang_ssp/internal/audit"
+	"golang_ssp/golang_ssp/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestSplitRemotePath(t *testing.T) {
+	testCases := []struct {
+		arg    string
+		host   string
+		path   string
+		remote bool
+	}{
+		{"node1:/tmp/", "node1", "/tmp/", true},
+		{"root@node1:", "root@node1", "", true},
+		{"file.tar", "", "file.tar", false},
+		{"./a:b", "", "./a:b", false},
+		{"/tmp/a:b", "", "/tmp/a:b", false},
+	}
+	for _, tc := range testCases {
+		host, p, remote := SplitRemotePath(tc.arg)
+		if host != tc.host || p != tc.path || remote != tc.remote {
+			t.Errorf("SplitRemotePath(%q): expected %q %q %v, got %q %q %v", tc.arg, tc.host, tc.path, tc.remote, host, p, remote)
+		}
+	}
+}
+
+func TestCopy(t *testing.T) {
+	port := startSFTPServer(t)
+	t.Setenv(audit.Env, "off")
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+
+	// 测试服务器直接使用本地文件系统
+	localDir := t.TempDir()
+	remoteDir := t.TempDir()
+	mtime := time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC)
+	os.MkdirAll(filepath.Join(localDir, "app", "conf"), 0755)
+	os.WriteFile(filepath.Join(localDir, "app", "run.sh"), []byte("#!/bin/sh\n"), 0700)
+	os.WriteFile(filepath.Join(localDir, "app", "conf", "a.conf"), []byte("a=1\n"), 0640)
+	os.Chtimes(filepath.Join(localDir, "app", "run.sh"), mtime, mtime)
+
+	opts := CopyOptions{Quiet: true}
+	if err := Copy(cfg, nil, "", []string{filepath.Join(localDir, "app")}, remoteDir, true, opts); err == nil {
+		t.Errorf("Expected error copying directory without -r")
+	}
+
+	opts = CopyOptions{Recursive: true, Preserve: true, Quiet: true}
+	if err := Copy(cfg, nil, "", []string{filepath.Join(localDir, "app")}, remoteDir, true, opts); err != nil {
+		t.Fatalf("Failed to upload: %v", err)
+	}
+	info, err := os.Stat(filepath.Join(remoteDir, "app", "run.sh"))
+	if err != nil || info.Mode().Perm() != 0700 || !info.ModTime().Equal(mtime) {
+		t.Errorf("Expected mode and mtime preserved, got %v %v", info, err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(remoteDir, "app", "conf", "a.conf")); string(content) != "a=1\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	// 远程通配符下载到目录
+	downloadDir := t.TempDir()
+	opts = CopyOptions{Quiet: true}
+	if err := Copy(cfg, nil, "", []string{filepath.Join(remoteDir, "app", "*.sh")}, downloadDir, false, opts); err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	}
+	if content, _ := os.ReadFile(filepath.Join(downloadDir, "run.sh")); string(content) != "#!/bin/sh\n" {
+		t.Errorf("Unexpected content %q", content)
+	}
+
+	// 单个文件下载为指定文件名
+	if err := Copy(cfg, nil, "", []string{filepath.Join(remoteDir, "app", "conf", "a.conf")}, filepath.Join(downloadDir, "b.conf"), false, opts); err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(downloadDir, "b.conf")); err != nil {
+		t.Errorf("Expected b.conf: %v", err)
+	}
+
+	// 多个源时目标必须是目录
+	if err := Copy(cfg, nil, "", []string{filepath.Join(remoteDir, "app", "*")}, filepath.Join(downloadDir, "b.conf"), false, opts); err == nil {
+		t.Errorf("Expected error copying multiple files to a file")
+	}
+
+	// 首次连接记录的主机密钥保存到缓存
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	cfgs := &[]config.SSHConfig{
+		{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"},
+		{Host: "test", Hostname: "127.0.0.1", User: "deploy", Port: port, Password: "1234"},
+	}
+	config.WriteConfig(configPath, *cfgs)
+	cfg = &config.SSHConfig{}
+	*cfg = (*cfgs)[0]
+	if err := Copy(cfg, cfgs, configPath, []string{filepath.Join(remoteDir, "app", "run.sh")}, downloadDir, false, opts); err != nil {
+		t.Fatalf("Failed to download: %v", err)
+	}
+	saved, _ := config.ReadConfig(configPath)
+	for _, c := range *saved {
+		if (c.User == "test") != (c.HostKey != "") {
+			t.Errorf("Expected host key saved only for test@127.0.0.1, got %s %q", c.Key(), c.HostKey)
+		}
+	}
+}
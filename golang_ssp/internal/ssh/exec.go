@@ -0,0 +1,256 @@
+package ssh
+
+import (
+	"bytes"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/audit"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"sync"
+	"time"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// ExecOptions ssp exec 的并发和超时设置
+type ExecOptions struct {
+	Workers int           // 同时连接的主机数
+	Timeout time.Duration // 每个主机的超时时间, 包括连接和执行, 0 表示不限制
+}
+
+// ExecResult 一个主机的执行结果, 连接失败或超时时 Err 不为空
+type ExecResult struct {
+	Host     string
+	ExitCode int
+	Err      error
+	Duration time.Duration
+}
+
+func (r *ExecResult) OK() bool {
+	return r.Err == nil && r.ExitCode == 0
+}
+
+// Exec 使用缓存的认证信息在多个主机上并发执行命令, 输出按行加上主机名前缀, 结果与 targets 顺序一致
+// 首次连接时记录的主机密钥会保存到缓存
+func Exec(targets []config.SSHConfig, cfgs *[]config.SSHConfig, configPath string, command string, opts ExecOptions, stdout, stderr io.Writer) []ExecResult {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = 1
+	}
+
+	width := 0
+	for _, t := range targets {
+		width = max(width, len(t.Host))
+	}
+
+	var outMu sync.Mutex
+	var keysMu sync.Mutex
+	pinned := map[string]string{}
+
+	results := make([]ExecResult, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				cfg := targets[i]
+				prefix := fmt.Sprintf("%-*s | ", width, cfg.Host)
+				out := &prefixWriter{prefix: prefix, out: stdout, mu: &outMu}
+				errOut := &prefixWriter{prefix: prefix, out: stderr, mu: &outMu}
+
+				entry := audit.Begin(&cfg, "exec")
+				start := time.Now()
+				code, keys, err := execHost(cfg, *cfgs, command, opts.Timeout, out, errOut)
+				out.Flush()
+				errOut.Flush()
+				results[i] = ExecResult{Host: cfg.Host, ExitCode: code, Err: err, Duration: time.Since(start)}
+				if code < 0 {
+					entry.Fail(err)
+				} else {
+					entry.Finish(code, err)
+				}
+
+				keysMu.Lock()
+				for host, key := range keys {
+					pinned[host] = key
+				}
+				keysMu.Unlock()
+			}
+		}()
+	}
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	savePinnedHostKeys(cfgs, configPath, pinned)
+	return results
+}
+
+// execHost 连接主机并执行命令, 超时后关闭连接
+// cfg 和 cfgs 是副本, 连接时记录的主机和跳板机密钥通过返回值带回
+func execHost(cfg config.SSHConfig, cfgs []config.SSHConfig, command string, timeout time.Duration, stdout, stderr io.Writer) (int, map[string]string, error) {
+	type result struct {
+		code int
+		keys map[string]string
+		err  error
+	}
+	done := make(chan result, 1)
+
+	var mu sync.Mutex
+	var client *gossh.Client
+	timedOut := false
+
+	go func() {
+		cfgs = append([]config.SSHConfig(nil), cfgs...)
+		c, err := Dial(&cfg, &cfgs)
+		if err != nil {
+			done <- result{-1, nil, err}
+			return
+		}
+		mu.Lock()
+		if timedOut {
+			mu.Unlock()
+			c.Close()
+			return
+		}
+		client = c
+		mu.Unlock()
+		defer c.Close()
+
+		session, err := c.NewSession()
+		if err != nil {
+			done <- result{-1, nil, fmt.Errorf("open session: %w", err)}
+			return
+		}
+		defer session.Close()
+		session.Stdout = stdout
+		session.Stderr = stderr
+
+		code, err := exitCode(session.Run(command))
+		keys := map[string]string{}
+		for _, h := range append(cfgs, cfg) {
+			if h.HostKey != "" {
+				keys[h.Key()] = h.HostKey
+			}
+		}
+		done <- result{code, keys, err}
+	}()
+
+	var timer <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		timer = t.C
+	}
+	select {
+	case r := <-done:
+		return r.code, r.keys, r.err
+	case <-timer:
+		mu.Lock()
+		timedOut = true
+		if client != nil {
+			client.Close()
+		}
+		mu.Unlock()
+		return -1, nil, fmt.Errorf("timeout after %s", timeout)
+	}
+}
+
+// savePinnedHostKeys 保存本次连接中新记录的主机密钥, 不覆盖已有的指纹
+func savePinnedHostKeys(cfgs *[]config.SSHConfig, configPath string, pinned map[string]string) {
+	if cfgs == nil || configPath == "" {
+		return
+	}
+	changed := false
+	for _, c := range *cfgs {
+		if key, ok := pinned[c.Key()]; ok && c.HostKey == "" && key != "" {
+			changed = true
+		}
+	}
+	if !changed {
+		return
+	}
+	latest, err := config.UpdateConfig(configPath, func(latest *[]config.SSHConfig) error {
+		for i := range *latest {
+			if key, ok := pinned[(*latest)[i].Key()]; ok && (*latest)[i].HostKey == "" {
+				(*latest)[i].HostKey = key
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Println("Error writing config:", err)
+		return
+	}
+	*cfgs = *latest
+}
+
+// PrintExecSummary 输出每个主机的退出码, 返回失败的主机数
+func PrintExecSummary(w io.Writer, results []ExecResult) int {
+	width := len("HOST")
+	for _, r := range results {
+		width = max(width, len(r.Host))
+	}
+
+	failed := 0
+	fmt.Fprintf(w, "\n%-*s  %-7s %-5s %s\n", width, "HOST", "STATUS", "EXIT", "TIME")
+	for _, r := range results {
+		status, code := "ok", fmt.Sprint(r.ExitCode)
+		if !r.OK() {
+			failed++
+			status = "failed"
+		}
+		if r.Err != nil {
+			status, code = "error", "-"
+		}
+		fmt.Fprintf(w, "%-*s  %-7s %-5s %s", width, r.Host, status, code, r.Duration.Round(10*time.Millisecond))
+		if r.Err != nil {
+			fmt.Fprintf(w, "  %v", r.Err)
+		}
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%d hosts, %d ok, %d failed\n", len(results), len(results)-failed, failed)
+	return failed
+}
+
+// prefixWriter 按行输出并在行首加上主机名, 多个主机共用一个锁, 避免输出交错
+type prefixWriter struct {
+	prefix string
+	out    io.Writer
+	mu     *sync.Mutex
+	buf    []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		w.writeLine(w.buf[:i+1])
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush 输出最后一行不以换行结尾的内容
+func (w *prefixWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.buf) > 0 {
+		w.writeLine(append(w.buf, '\n'))
+		w.buf = nil
+	}
+}
+
+func (w *prefixWriter) writeLine(line []byte) {
+	io.WriteString(w.out, w.prefix)
+	w.out.Write(line)
+}
@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"bytes"
+	"golang_ssp/golang_ssp/internal/audit"
+	"golang_ssp/golang_ssp/internal/config"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	ssh3 "github.com/gliderlabs/ssh"
+)
+
+func TestExec(t *testing.T) {
+	port := startTestServer(t, func(s ssh3.Session) {
+		switch s.RawCommand() {
+		case "hello":
+			s.Write([]byte("hello\nworld"))
+			s.Exit(0)
+		case "fail":
+			s.Stderr().Write([]byte("boom\n"))
+			s.Exit(3)
+		case "sleep":
+			time.Sleep(2 * time.Second)
+			s.Exit(0)
+		}
+	})
+
+	configPath := filepath.Join(t.TempDir(), "config_cache")
+	historyPath := filepath.Join(t.TempDir(), "history.jsonl")
+	t.Setenv(audit.Env, historyPath)
+	cfgs := &[]config.SSHConfig{
+		{Host: "web1", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"},
+		{Host: "web2", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"},
+		{Host: "web3", Hostname: "127.0.0.1", User: "test", Port: port, Password: "wrong"},
+	}
+	config.WriteConfig(configPath, *cfgs)
+
+	var stdout, stderr bytes.Buffer
+	results := Exec((*cfgs)[:2], cfgs, configPath, "hello", ExecOptions{Workers: 2}, &stdout, &stderr)
+	for _, s := range []string{"web1 | hello\n", "web1 | world\n", "web2 | hello\n"} {
+		if !strings.Contains(stdout.String(), s) {
+			t.Errorf("Expected output to contain %q, got:\n%s", s, stdout.String())
+		}
+	}
+	if !results[0].OK() || !results[1].OK() {
+		t.Errorf("Expected all hosts ok, got %+v", results)
+	}
+
+	// 首次连接记录的主机密钥保存到缓存
+	saved, _ := config.ReadConfig(configPath)
+	for _, c := range *saved {
+		if c.Host != "web3" && c.HostKey == "" {
+			t.Errorf("Expected host key of %s to be saved", c.Host)
+		}
+	}
+
+	stdout.Reset()
+	results = Exec(*cfgs, cfgs, configPath, "fail", ExecOptions{Workers: 3}, &stdout, &stderr)
+	if results[0].ExitCode != 3 || results[0].Err != nil || results[2].Err == nil {
+		t.Errorf("Expected exit code 3 and auth error, got %+v", results)
+	}
+	if !strings.Contains(stderr.String(), "web2 | boom\n") {
+		t.Errorf("Expected prefixed stderr, got:\n%s", stderr.String())
+	}
+
+	var summary bytes.Buffer
+	if failed := PrintExecSummary(&summary, results); failed != 3 {
+		t.Errorf("Expected 3 failed hosts, got %d:\n%s", failed, summary.String())
+	}
+
+	// 每个主机的执行结果都记录到登录历史
+	entries, _ := audit.ReadFile(historyPath, &audit.Filter{Host: "web3"})
+	if len(entries) != 1 || entries[0].Status != audit.StatusFailed || entries[0].Error == "" || entries[0].Command != "exec" {
+		t.Errorf("Expected failed login of web3 in history, got %+v", entries)
+	}
+	entries, _ = audit.ReadFile(historyPath, &audit.Filter{Failed: true})
+	if len(entries) != 3 {
+		t.Errorf("Expected 3 failed entries in history, got %+v", entries)
+	}
+
+	results = Exec((*cfgs)[:1], cfgs, "", "sleep", ExecOptions{Workers: 1, Timeout: 200 * time.Millisecond}, &stdout, &stderr)
+	if results[0].Err == nil || !strings.Contains(results[0].Err.Error(), "timeout") {
+		t.Errorf("Expected timeout, got %+v", results[0])
+	}
+}
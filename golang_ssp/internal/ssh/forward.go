@@ -0,0 +1,186 @@
+package ssh
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// forwarder 管理一个 ssh 连接上的所有端口转发
+type forwarder struct {
+	client    *gossh.Client
+	listeners []net.Listener
+	active    []config.Forward
+}
+
+// startForwards 启动端口转发, 单个转发失败 (如端口被占用) 时打印告警并继续
+func startForwards(client *gossh.Client, forwards []config.Forward) *forwarder {
+	f := &forwarder{client: client}
+	for _, fw := range forwards {
+		if err := f.start(fw); err != nil {
+			fmt.Printf("Warning: forward %s failed: %v\n", fw.Spec(), err)
+			continue
+		}
+		f.active = append(f.active, fw)
+	}
+	return f
+}
+
+func (f *forwarder) start(fw config.Forward) error {
+	var l net.Listener
+	var err error
+	var handle func(net.Conn)
+
+	switch fw.Type {
+	case config.ForwardLocal:
+		l, err = net.Listen("tcp", fw.ListenAddr())
+		handle = func(conn net.Conn) {
+			remote, err := f.client.Dial("tcp", fw.Target)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			pipe(conn, remote)
+		}
+	case config.ForwardRemote:
+		l, err = f.client.Listen("tcp", fw.ListenAddr())
+		handle = func(conn net.Conn) {
+			local, err := net.Dial("tcp", fw.Target)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			pipe(conn, local)
+		}
+	case config.ForwardDynamic:
+		l, err = net.Listen("tcp", fw.ListenAddr())
+		handle = func(conn net.Conn) {
+			remote, err := socks5Connect(conn, f.client)
+			if err != nil {
+				conn.Close()
+				return
+			}
+			pipe(conn, remote)
+		}
+	default:
+		return fmt.Errorf("unknown forward type %q", fw.Type)
+	}
+	if err != nil {
+		return err
+	}
+
+	f.listeners = append(f.listeners, l)
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go handle(conn)
+		}
+	}()
+	return nil
+}
+
+func (f *forwarder) Close() {
+	for _, l := range f.listeners {
+		l.Close()
+	}
+}
+
+// pipe 双向拷贝数据, 任意一端结束后关闭两端
+func pipe(a, b net.Conn) {
+	var once sync.Once
+	closeBoth := func() {
+		a.Close()
+		b.Close()
+	}
+	go func() {
+		io.Copy(a, b)
+		once.Do(closeBoth)
+	}()
+	io.Copy(b, a)
+	once.Do(closeBoth)
+}
+
+// socks5Connect 处理 SOCKS5 握手 (仅支持无认证的 CONNECT), 通过 ssh 连接目标地址
+func socks5Connect(conn net.Conn, client *gossh.Client) (net.Conn, error) {
+	// 版本和认证方式
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return nil, err
+	}
+	if header[0] != 5 {
+		return nil, errors.New("unsupported socks version")
+	}
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 0}); err != nil {
+		return nil, err
+	}
+
+	// 请求: VER CMD RSV ATYP DST.ADDR DST.PORT
+	req := make([]byte, 4)
+	if _, err := io.ReadFull(conn, req); err != nil {
+		return nil, err
+	}
+	if req[1] != 1 {
+		conn.Write([]byte{5, 7, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, errors.New("unsupported socks command")
+	}
+
+	var host string
+	switch req[3] {
+	case 1: // IPv4
+		addr := make([]byte, 4)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return nil, err
+		}
+		host = net.IP(addr).String()
+	case 3: // 域名
+		size := make([]byte, 1)
+		if _, err := io.ReadFull(conn, size); err != nil {
+			return nil, err
+		}
+		addr := make([]byte, size[0])
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return nil, err
+		}
+		host = string(addr)
+	case 4: // IPv6
+		addr := make([]byte, 16)
+		if _, err := io.ReadFull(conn, addr); err != nil {
+			return nil, err
+		}
+		host = net.IP(addr).String()
+	default:
+		conn.Write([]byte{5, 8, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, errors.New("unsupported socks address type")
+	}
+
+	port := make([]byte, 2)
+	if _, err := io.ReadFull(conn, port); err != nil {
+		return nil, err
+	}
+	target := net.JoinHostPort(host, strconv.Itoa(int(binary.BigEndian.Uint16(port))))
+
+	remote, err := client.Dial("tcp", target)
+	if err != nil {
+		conn.Write([]byte{5, 5, 0, 1, 0, 0, 0, 0, 0, 0})
+		return nil, err
+	}
+	if _, err := conn.Write([]byte{5, 0, 0, 1, 0, 0, 0, 0, 0, 0}); err != nil {
+		remote.Close()
+		return nil, err
+	}
+	return remote, nil
+}
@@ -0,0 +1,106 @@
+package ssh
+
+import (
+	"bufio"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"net"
+	"testing"
+)
+
+// startEchoServer 启动本地 tcp echo 服务, 返回监听地址
+func startEchoServer(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// freePort 返回一个当前未被占用的本地端口
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	_, port, _ := net.SplitHostPort(l.Addr().String())
+	return port
+}
+
+func expectEcho(t *testing.T, conn net.Conn) {
+	defer conn.Close()
+	conn.Write([]byte("ping\n"))
+	line, err := bufio.NewReader(conn).ReadString('\n')
+	if err != nil || line != "ping\n" {
+		t.Errorf("Expected echo 'ping', got %q (%v)", line, err)
+	}
+}
+
+func TestForwards(t *testing.T) {
+	port := startBastionServer(t)
+	echo := startEchoServer(t)
+
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+	client, err := Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	localPort := freePort(t)
+	socksPort := freePort(t)
+	local, _ := config.ParseForward(config.ForwardLocal, localPort+":"+echo)
+	dynamic, _ := config.ParseForward(config.ForwardDynamic, socksPort)
+
+	fw := startForwards(client, []config.Forward{local, dynamic})
+	defer fw.Close()
+	if len(fw.active) != 2 {
+		t.Fatalf("Expected 2 active forwards, got %v", fw.active)
+	}
+
+	// -L
+	conn, err := net.Dial("tcp", "127.0.0.1:"+localPort)
+	if err != nil {
+		t.Fatalf("Failed to connect local forward: %v", err)
+	}
+	expectEcho(t, conn)
+
+	// -D, SOCKS5 CONNECT 127.0.0.1:echo
+	conn, err = net.Dial("tcp", "127.0.0.1:"+socksPort)
+	if err != nil {
+		t.Fatalf("Failed to connect socks forward: %v", err)
+	}
+	echoAddr, _ := net.ResolveTCPAddr("tcp", echo)
+	conn.Write([]byte{5, 1, 0})
+	reply := make([]byte, 2)
+	io.ReadFull(conn, reply)
+	req := []byte{5, 1, 0, 1}
+	req = append(req, echoAddr.IP.To4()...)
+	req = append(req, byte(echoAddr.Port>>8), byte(echoAddr.Port))
+	conn.Write(req)
+	reply = make([]byte, 10)
+	if _, err := io.ReadFull(conn, reply); err != nil || reply[1] != 0 {
+		t.Fatalf("Expected socks connect to succeed, got %v (%v)", reply, err)
+	}
+	expectEcho(t, conn)
+
+	// 端口被占用时跳过
+	busy := startForwards(client, []config.Forward{local})
+	if len(busy.active) != 0 {
+		t.Errorf("Expected forward on busy port to fail")
+	}
+}
@@ -0,0 +1,144 @@
+package ssh
+
+import (
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"net"
+	"os"
+
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyMismatchError 服务器主机密钥与缓存中记录的指纹不一致
+type HostKeyMismatchError struct {
+	Host     string
+	Address  string
+	Expected string
+	Actual   string
+}
+
+func (e *HostKeyMismatchError) Error() string {
+	return fmt.Sprintf("host key mismatch for %s (%s): expected %s, got %s", e.Host, e.Address, e.Expected, e.Actual)
+}
+
+// Warning 返回给用户看的告警信息
+func (e *HostKeyMismatchError) Warning() string {
+	return fmt.Sprintf(`@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+@    WARNING: REMOTE HOST IDENTIFICATION HAS CHANGED!     @
+@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@@
+IT IS POSSIBLE THAT SOMEONE IS DOING SOMETHING NASTY!
+The host key of %s (%s) does not match the cached one.
+  Cached fingerprint: %s
+  Server fingerprint: %s
+If the server key was changed on purpose, accept the new key with:
+  ssp -accept-key %s
+`, e.Host, e.Address, e.Expected, e.Actual, e.Host)
+}
+
+// hostKeyCallback 首次连接时记录主机密钥指纹 (trust-on-first-use), 之后每次校验
+// 记录的指纹只有在登录成功后才会写入缓存
+func hostKeyCallback(cfg *config.SSHConfig) gossh.HostKeyCallback {
+	return func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		fingerprint := gossh.FingerprintSHA256(key)
+		if cfg.HostKey == "" {
+			// 并发 check/exec 时输出到 stderr 并以主机名开头, 不混入结果表格
+			fmt.Fprintf(os.Stderr, "%s: Warning: recording host key %s\n", cfg.Host, fingerprint)
+			cfg.HostKey = fingerprint
+			return nil
+		}
+		if cfg.HostKey != fingerprint {
+			// cfg 是正在握手的这一跳, 跳板机的密钥变化报告跳板机自己的地址
+			return &HostKeyMismatchError{Host: cfg.Host, Address: cfg.Address(), Expected: cfg.HostKey, Actual: fingerprint}
+		}
+		return nil
+	}
+}
+
+var errHostKeyFetched = errors.New("host key fetched")
+
+// FetchHostKey 只做密钥交换获取服务器主机密钥指纹, 不对目标主机进行认证
+func FetchHostKey(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (string, error) {
+	key, err := fetchHostKey(cfg, cfgs)
+	if err != nil {
+		return "", err
+	}
+	return gossh.FingerprintSHA256(key), nil
+}
+
+func fetchHostKey(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (gossh.PublicKey, error) {
+	jump, err := dialJumpHost(cfg, cfgs, map[string]bool{})
+	if err != nil {
+		return nil, err
+	}
+	if jump != nil {
+		defer jump.Close()
+	}
+
+	var hostKey gossh.PublicKey
+	conf := clientConfig(cfg, nil)
+	conf.HostKeyCallback = func(hostname string, remote net.Addr, key gossh.PublicKey) error {
+		hostKey = key
+		return errHostKeyFetched
+	}
+
+	client, err := dialVia(jump, cfg.Address(), conf)
+	if client != nil {
+		client.Close()
+	}
+	if hostKey != nil {
+		return hostKey, nil
+	}
+	return nil, err
+}
+
+// writeKnownHosts 获取服务器的主机公钥, 与缓存的指纹一致时写入临时 known_hosts 文件,
+// 以 Host 作为 HostKeyAlias, 供外部 ssh 严格校验; 调用方负责删除返回的文件
+func writeKnownHosts(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (string, error) {
+	key, err := fetchHostKey(cfg, cfgs)
+	if err != nil {
+		return "", err
+	}
+	if fingerprint := gossh.FingerprintSHA256(key); fingerprint != cfg.HostKey {
+		return "", &HostKeyMismatchError{Host: cfg.Host, Address: cfg.Address(), Expected: cfg.HostKey, Actual: fingerprint}
+	}
+
+	file, err := os.CreateTemp("", "ssp-known_hosts-*")
+	if err != nil {
+		return "", err
+	}
+	_, err = fmt.Fprintln(file, knownhosts.Line([]string{cfg.Host}, key))
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
+
+// AcceptHostKey 信任服务器当前的主机密钥, 更新缓存中的指纹
+func AcceptHostKey(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string) error {
+	fingerprint, err := FetchHostKey(cfg, cfgs)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Host %s (%s)\n  Old fingerprint: %s\n  New fingerprint: %s\n", cfg.Host, cfg.Hostname, cfg.HostKey, fingerprint)
+	latest, err := config.UpdateConfig(configPath, func(latest *[]config.SSHConfig) error {
+		// 主机密钥属于服务器, 同一服务器的所有账号一起更新
+		for i, c := range *latest {
+			if c.Address() == cfg.Address() {
+				(*latest)[i].HostKey = fingerprint
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	*cfgs = *latest
+	return nil
+}
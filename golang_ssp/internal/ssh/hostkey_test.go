@@ -0,0 +1,87 @@
+package ssh
+
+import (
+	"errors"
+	"golang_ssp/golang_ssp/internal/config"
+	"net"
+	"os"
+	"testing"
+
+	ssh3 "github.com/gliderlabs/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+func TestHostKeyPinning(t *testing.T) {
+	handler := func(s ssh3.Session) { s.Exit(0) }
+	// 两个服务器各自生成不同的主机密钥
+	port1 := startTestServer(t, handler)
+	port2 := startTestServer(t, handler)
+
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port1, Password: "1234"}
+
+	// 首次连接记录指纹
+	client, err := Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	client.Close()
+	if cfg.HostKey == "" {
+		t.Fatalf("Expected host key to be recorded")
+	}
+	pinned := cfg.HostKey
+
+	// 再次连接校验通过
+	client, err = Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial with pinned key: %v", err)
+	}
+	client.Close()
+
+	// 主机密钥变化
+	cfg.Port = port2
+	_, err = Dial(cfg, nil)
+	var mismatch *HostKeyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected HostKeyMismatchError, got %v", err)
+	}
+	if mismatch.Expected != pinned || mismatch.Actual == pinned {
+		t.Errorf("Unexpected fingerprints: %v", mismatch)
+	}
+
+	// 不认证也能获取新指纹
+	cfg.Password = "wrong"
+	fingerprint, err := FetchHostKey(cfg, nil)
+	if err != nil || fingerprint != mismatch.Actual {
+		t.Errorf("Expected fingerprint %s, got %s (%v)", mismatch.Actual, fingerprint, err)
+	}
+}
+
+func TestWriteKnownHosts(t *testing.T) {
+	port := startTestServer(t, func(s ssh3.Session) { s.Exit(0) })
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+	key, err := fetchHostKey(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to fetch host key: %v", err)
+	}
+
+	cfg.HostKey = "SHA256:other"
+	if _, err := writeKnownHosts(cfg, nil); err == nil {
+		t.Errorf("Expected error when the key does not match the pinned fingerprint")
+	}
+
+	// 写入的公钥以 Host 作为别名, 能通过 known_hosts 校验
+	cfg.HostKey, _ = FetchHostKey(cfg, nil)
+	path, err := writeKnownHosts(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to write known_hosts: %v", err)
+	}
+	defer os.Remove(path)
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		t.Fatalf("Failed to read known_hosts: %v", err)
+	}
+	addr := &net.TCPAddr{IP: net.ParseIP("127.0.0.1"), Port: int(port)}
+	if err := callback("test:22", addr, key); err != nil {
+		t.Errorf("Expected pinned key to be accepted for alias test: %v", err)
+	}
+}
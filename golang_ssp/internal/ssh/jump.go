@@ -0,0 +1,89 @@
+package ssh
+
+import (
+	"context"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"strings"
+
+	gossh "golang.org/x/crypto/ssh"
+)
+
+// findJumpHost 在缓存中查找 ProxyJump 引用的跳板机, seen 用于检测循环引用
+// 返回的指针指向 cfgs 中的条目, 跳板机首次记录的主机密钥会随缓存一起保存
+func findJumpHost(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, seen map[string]bool) (*config.SSHConfig, error) {
+	if cfgs == nil {
+		return nil, fmt.Errorf("jump host %s of %s not found in cache", cfg.ProxyJump, cfg.Host)
+	}
+	seen[cfg.Host] = true
+	if seen[cfg.ProxyJump] {
+		return nil, fmt.Errorf("ProxyJump loop detected: %s -> %s", cfg.Host, cfg.ProxyJump)
+	}
+	// 跳板机有多个账号时可以写成 user@host
+	user, host, ok := strings.Cut(cfg.ProxyJump, "@")
+	if !ok {
+		user, host = "", cfg.ProxyJump
+	}
+	for i := range *cfgs {
+		if (*cfgs)[i].Host == host && (user == "" || (*cfgs)[i].User == user) {
+			return &(*cfgs)[i], nil
+		}
+	}
+	return nil, fmt.Errorf("jump host %s of %s not found in cache", cfg.ProxyJump, cfg.Host)
+}
+
+// dialJumpHost 连接 cfg 的跳板机链, 没有配置 ProxyJump 时返回 nil
+func dialJumpHost(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, seen map[string]bool) (*gossh.Client, error) {
+	if cfg.ProxyJump == "" {
+		return nil, nil
+	}
+	hop, err := findJumpHost(cfg, cfgs, seen)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dial(hop, cfgs, seen)
+	if err != nil {
+		return nil, fmt.Errorf("jump host %s: %w", hop.Host, err)
+	}
+	return client, nil
+}
+
+// dialVia 通过跳板机 via 建立到 addr 的 ssh 连接, via 为 nil 时直接连接
+func dialVia(via *gossh.Client, addr string, conf *gossh.ClientConfig) (*gossh.Client, error) {
+	if via == nil {
+		return gossh.Dial("tcp", addr, conf)
+	}
+
+	// 与直接连接一样, conf.Timeout 限制打开转发通道和握手的总时间
+	ctx := context.Background()
+	if conf.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, conf.Timeout)
+		defer cancel()
+	}
+	conn, err := via.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial %s via jump host: %w", addr, err)
+	}
+	// 转发通道不支持 SetDeadline, 超时后关闭连接中断握手
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	c, chans, reqs, err := gossh.NewClientConn(conn, addr, conf)
+	if !stop() && ctx.Err() != nil {
+		if err == nil {
+			c.Close()
+		}
+		return nil, fmt.Errorf("ssh handshake with %s via jump host: %w", addr, ctx.Err())
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	client := gossh.NewClient(c, chans, reqs)
+	// 目标连接关闭后同时关闭跳板机连接
+	go func() {
+		client.Wait()
+		via.Close()
+	}()
+	return client, nil
+}
@@ -0,0 +1,121 @@
+package ssh
+
+import (
+	"errors"
+	"golang_ssp/golang_ssp/internal/config"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	ssh3 "github.com/gliderlabs/ssh"
+)
+
+// startBastionServer 启动允许 direct-tcpip 转发的跳板机
+func startBastionServer(t *testing.T) uint16 {
+	return startTestServer(t, func(s ssh3.Session) { s.Exit(0) }, func(srv *ssh3.Server) error {
+		srv.LocalPortForwardingCallback = func(ctx ssh3.Context, host string, port uint32) bool { return true }
+		srv.ChannelHandlers = map[string]ssh3.ChannelHandler{
+			"session":      ssh3.DefaultSessionHandler,
+			"direct-tcpip": ssh3.DirectTCPIPHandler,
+		}
+		return nil
+	})
+}
+
+func TestProxyJump(t *testing.T) {
+	bastion1 := startBastionServer(t)
+	bastion2 := startBastionServer(t)
+	target := startTestServer(t, func(s ssh3.Session) { s.Exit(0) })
+
+	cfgs := []config.SSHConfig{
+		{Host: "bastion1", Hostname: "127.0.0.1", User: "test", Port: bastion1, Password: "1234"},
+		{Host: "bastion2", Hostname: "127.0.0.1", User: "test", Port: bastion2, Password: "1234", ProxyJump: "bastion1"},
+	}
+	cfg := &config.SSHConfig{Host: "target", Hostname: "127.0.0.1", User: "test", Port: target, Password: "1234", ProxyJump: "bastion2"}
+
+	if !checkConnection(cfg, &cfgs) {
+		t.Fatalf("Expected connection through jump hosts to succeed")
+	}
+	// 每一跳的主机密钥都被记录
+	for _, c := range cfgs {
+		if c.HostKey == "" {
+			t.Errorf("Expected host key of %s to be recorded", c.Host)
+		}
+	}
+
+	// 跳板机密码错误
+	cfgs[0].Password = "wrong"
+	if checkConnection(cfg, &cfgs) {
+		t.Fatalf("Expected connection with wrong jump host password to fail")
+	}
+
+	// 跳板机不存在
+	cfg.ProxyJump = "missing"
+	if _, err := Dial(cfg, &cfgs); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("Expected jump host not found error, got %v", err)
+	}
+
+	// 循环引用
+	cfgs[0].ProxyJump = "bastion2"
+	cfg.ProxyJump = "bastion2"
+	if _, err := Dial(cfg, &cfgs); err == nil || !strings.Contains(err.Error(), "loop") {
+		t.Errorf("Expected ProxyJump loop error, got %v", err)
+	}
+}
+
+func TestProxyJumpTimeout(t *testing.T) {
+	bastion := startBastionServer(t)
+	// 接受连接但不发送 ssh banner 的目标主机
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer l.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		if conn, err := l.Accept(); err == nil {
+			<-done
+			conn.Close()
+		}
+	}()
+
+	oldTimeout := dialTimeout
+	dialTimeout = 500 * time.Millisecond
+	defer func() { dialTimeout = oldTimeout }()
+
+	cfgs := []config.SSHConfig{{Host: "bastion", Hostname: "127.0.0.1", User: "test", Port: bastion, Password: "1234"}}
+	cfg := &config.SSHConfig{Host: "stalled", Hostname: "127.0.0.1", User: "test", Port: uint16(l.Addr().(*net.TCPAddr).Port), Password: "1234", ProxyJump: "bastion"}
+	start := time.Now()
+	_, err = Dial(cfg, &cfgs)
+	if err == nil || !isTimeout(err) {
+		t.Errorf("Expected timeout error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected dial to give up after the timeout, took %v", elapsed)
+	}
+}
+
+func TestProxyJumpHostKeyMismatch(t *testing.T) {
+	bastion := startBastionServer(t)
+	target := startTestServer(t, func(s ssh3.Session) { s.Exit(0) })
+
+	cfgs := []config.SSHConfig{{Host: "bastion", Hostname: "127.0.0.1", User: "test", Port: bastion, Password: "1234", HostKey: "SHA256:old"}}
+	cfg := &config.SSHConfig{Host: "target", Hostname: "127.0.0.1", User: "test", Port: target, Password: "1234", ProxyJump: "bastion"}
+
+	// 跳板机的密钥变化报告为跳板机的错误, 而不是目标主机
+	_, err := Dial(cfg, &cfgs)
+	var mismatch *HostKeyMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected HostKeyMismatchError, got %v", err)
+	}
+	if mismatch.Host != "bastion" || mismatch.Address != cfgs[0].Address() {
+		t.Errorf("Expected mismatch of bastion (%s), got %s (%s)", cfgs[0].Address(), mismatch.Host, mismatch.Address)
+	}
+
+	r := checkHost(*cfg, cfgs, time.Second)
+	if r.Status != CheckHostKeyMismatch || r.HostKey != "-" {
+		t.Errorf("Expected jump host mismatch without checking the target key, got %+v", r)
+	}
+}
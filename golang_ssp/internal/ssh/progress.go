@@ -0,0 +1,70 @@
+package ssh
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// progress 传输进度条, 包装 io.Writer 统计已写入的字节数
+type progress struct {
+	name    string
+	total   int64
+	current int64
+	out     io.Writer
+	start   time.Time
+	last    time.Time
+}
+
+func newProgress(name string, total int64, out io.Writer) *progress {
+	return &progress{name: name, total: total, out: out, start: time.Now()}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.current += int64(len(b))
+	// 刷新频率限制, 避免大量小块写入时刷屏
+	if time.Since(p.last) > 100*time.Millisecond {
+		p.print()
+		p.last = time.Now()
+	}
+	return len(b), nil
+}
+
+func (p *progress) print() {
+	percent := 100
+	if p.total > 0 {
+		percent = int(p.current * 100 / p.total)
+	}
+	// 传输过程中文件变大时已写入的字节数会超过 total
+	percent = max(0, min(percent, 100))
+	width := 30
+	done := width * percent / 100
+	bar := strings.Repeat("=", done) + strings.Repeat(" ", width-done)
+
+	speed := 0.0
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		speed = float64(p.current) / elapsed
+	}
+	fmt.Fprintf(p.out, "\r%-30s %3d%% [%s] %s/%s %s/s", p.name, percent, bar, formatSize(p.current), formatSize(p.total), formatSize(int64(speed)))
+}
+
+// Done 输出最终进度并换行
+func (p *progress) Done() {
+	p.print()
+	fmt.Fprintln(p.out)
+}
+
+func formatSize(n int64) string {
+	units := []string{"B", "KB", "MB", "GB", "TB"}
+	size := float64(n)
+	i := 0
+	for size >= 1024 && i < len(units)-1 {
+		size /= 1024
+		i++
+	}
+	if i == 0 {
+		return fmt.Sprintf("%dB", n)
+	}
+	return fmt.Sprintf("%.1f%s", size, units[i])
+}
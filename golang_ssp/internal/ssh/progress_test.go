@@ -0,0 +1,25 @@
+package ssh
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestProgress(t *testing.T) {
+	var out bytes.Buffer
+	p := newProgress("app.log", 10, &out)
+	p.Write(make([]byte, 5))
+	p.Done()
+	if !strings.Contains(out.String(), " 50% [===============               ]") {
+		t.Errorf("Expected half progress, got %q", out.String())
+	}
+
+	// 传输过程中文件变大
+	out.Reset()
+	p.Write(make([]byte, 20))
+	p.Done()
+	if !strings.Contains(out.String(), "100% [==============================]") {
+		t.Errorf("Expected progress clamped to 100%%, got %q", out.String())
+	}
+}
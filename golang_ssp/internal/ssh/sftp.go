@@ -0,0 +1,330 @@
+package ssh
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/sftp"
+	gossh "golang.org/x/crypto/ssh"
+	"golang.org/x/term"
+)
+
+const sftpHelp = `Available commands:
+  ls [path]                 List remote directory
+  cd path                   Change remote directory
+  pwd                       Print remote directory
+  get remote [local]        Download file
+  put local [remote]        Upload file
+  mkdir path                Create remote directory
+  rm path                   Remove remote file
+  rmdir path                Remove remote directory
+  lls [path]                List local directory
+  lcd path                  Change local directory
+  lpwd                      Print local directory
+  help                      Show this help
+  exit/quit/bye             Quit sftp
+`
+
+// sftpShell 基于原生 ssh 连接的交互式 sftp
+type sftpShell struct {
+	client    *sftp.Client
+	out       io.Writer
+	remoteDir string
+	localDir  string
+}
+
+// SFTPShell 在 ssh 连接上打开交互式 sftp, 不依赖 sftp/sshpass 命令
+func SFTPShell(client *gossh.Client) error {
+	c, err := sftp.NewClient(client)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	sh, err := newSFTPShell(c)
+	if err != nil {
+		return err
+	}
+
+	// 本地是终端时使用 term.Terminal, 支持行编辑和历史记录
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return err
+		}
+		defer term.Restore(fd, state)
+
+		t := term.NewTerminal(struct {
+			io.Reader
+			io.Writer
+		}{os.Stdin, os.Stdout}, "sftp> ")
+		if width, height, err := term.GetSize(fd); err == nil {
+			t.SetSize(width, height)
+		}
+		sh.out = t
+		return sh.run(t.ReadLine)
+	}
+
+	sh.out = os.Stdout
+	scanner := bufio.NewScanner(os.Stdin)
+	return sh.run(func() (string, error) {
+		fmt.Fprint(os.Stdout, "sftp> ")
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", err
+			}
+			return "", io.EOF
+		}
+		return scanner.Text(), nil
+	})
+}
+
+func newSFTPShell(client *sftp.Client) (*sftpShell, error) {
+	remoteDir, err := client.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	localDir, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	return &sftpShell{client: client, out: os.Stdout, remoteDir: remoteDir, localDir: localDir}, nil
+}
+
+func (s *sftpShell) run(readLine func() (string, error)) error {
+	for {
+		line, err := readLine()
+		if err == io.EOF {
+			fmt.Fprintln(s.out)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		args := strings.Fields(line)
+		if len(args) == 0 {
+			continue
+		}
+		if args[0] == "exit" || args[0] == "quit" || args[0] == "bye" {
+			return nil
+		}
+		if err := s.exec(args[0], args[1:]); err != nil {
+			fmt.Fprintf(s.out, "%s: %v\n", args[0], err)
+		}
+	}
+}
+
+func (s *sftpShell) exec(cmd string, args []string) error {
+	switch cmd {
+	case "help", "?":
+		fmt.Fprint(s.out, sftpHelp)
+		return nil
+	case "pwd":
+		fmt.Fprintf(s.out, "Remote working directory: %s\n", s.remoteDir)
+		return nil
+	case "lpwd":
+		fmt.Fprintf(s.out, "Local working directory: %s\n", s.localDir)
+		return nil
+	case "ls":
+		return s.ls(optionalArg(args, "."))
+	case "lls":
+		return s.lls(optionalArg(args, "."))
+	case "cd":
+		return s.cd(optionalArg(args, ""))
+	case "lcd":
+		return s.lcd(optionalArg(args, ""))
+	}
+
+	if len(args) == 0 {
+		return errors.New("missing argument, see help")
+	}
+	switch cmd {
+	case "get":
+		return s.get(args[0], optionalArg(args[1:], path.Base(args[0])))
+	case "put":
+		return s.put(args[0], optionalArg(args[1:], filepath.Base(args[0])))
+	case "mkdir":
+		return s.client.Mkdir(s.remotePath(args[0]))
+	case "rm":
+		return s.client.Remove(s.remotePath(args[0]))
+	case "rmdir":
+		return s.client.RemoveDirectory(s.remotePath(args[0]))
+	}
+	return fmt.Errorf("unknown command, see help")
+}
+
+func optionalArg(args []string, def string) string {
+	if len(args) > 0 {
+		return args[0]
+	}
+	return def
+}
+
+func (s *sftpShell) remotePath(p string) string {
+	if path.IsAbs(p) {
+		return path.Clean(p)
+	}
+	return path.Join(s.remoteDir, p)
+}
+
+func (s *sftpShell) localPath(p string) string {
+	if strings.HasPrefix(p, "~") {
+		if home, err := os.UserHomeDir(); err == nil {
+			p = filepath.Join(home, p[1:])
+		}
+	}
+	if filepath.IsAbs(p) {
+		return filepath.Clean(p)
+	}
+	return filepath.Join(s.localDir, p)
+}
+
+func (s *sftpShell) ls(p string) error {
+	dir := s.remotePath(p)
+	infos, err := s.client.ReadDir(dir)
+	if err != nil {
+		// 不是目录时显示文件本身
+		info, statErr := s.client.Stat(dir)
+		if statErr != nil || info.IsDir() {
+			return err
+		}
+		infos = []os.FileInfo{info}
+	}
+	printFileInfos(s.out, infos)
+	return nil
+}
+
+func (s *sftpShell) lls(p string) error {
+	entries, err := os.ReadDir(s.localPath(p))
+	if err != nil {
+		return err
+	}
+	var infos []os.FileInfo
+	for _, entry := range entries {
+		if info, err := entry.Info(); err == nil {
+			infos = append(infos, info)
+		}
+	}
+	printFileInfos(s.out, infos)
+	return nil
+}
+
+func printFileInfos(out io.Writer, infos []os.FileInfo) {
+	for _, info := range infos {
+		name := info.Name()
+		if info.IsDir() {
+			name += "/"
+		}
+		fmt.Fprintf(out, "%-11s %10s %s %s\n", info.Mode(), formatSize(info.Size()), info.ModTime().Format("Jan _2 15:04"), name)
+	}
+}
+
+func (s *sftpShell) cd(p string) error {
+	if p == "" {
+		home, err := s.client.Getwd()
+		if err != nil {
+			return err
+		}
+		p = home
+	}
+	dir := s.remotePath(p)
+	info, err := s.client.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	s.remoteDir = dir
+	return nil
+}
+
+func (s *sftpShell) lcd(p string) error {
+	if p == "" {
+		p = "~"
+	}
+	dir := s.localPath(p)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", dir)
+	}
+	s.localDir = dir
+	return nil
+}
+
+func (s *sftpShell) get(remote, local string) error {
+	src, err := s.client.Open(s.remotePath(remote))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", remote)
+	}
+
+	localFile := s.localPath(local)
+	if stat, err := os.Stat(localFile); err == nil && stat.IsDir() {
+		localFile = filepath.Join(localFile, path.Base(remote))
+	}
+	dst, err := os.OpenFile(localFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, info.Mode().Perm())
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	bar := newProgress(path.Base(remote), info.Size(), s.out)
+	if _, err := io.Copy(io.MultiWriter(dst, bar), src); err != nil {
+		return err
+	}
+	bar.Done()
+	return nil
+}
+
+func (s *sftpShell) put(local, remote string) error {
+	src, err := os.Open(s.localPath(local))
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	info, err := src.Stat()
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", local)
+	}
+
+	remoteFile := s.remotePath(remote)
+	if stat, err := s.client.Stat(remoteFile); err == nil && stat.IsDir() {
+		remoteFile = path.Join(remoteFile, filepath.Base(local))
+	}
+	dst, err := s.client.OpenFile(remoteFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	bar := newProgress(filepath.Base(local), info.Size(), s.out)
+	if _, err := io.Copy(io.MultiWriter(dst, bar), src); err != nil {
+		return err
+	}
+	bar.Done()
+	return dst.Chmod(info.Mode().Perm())
+}
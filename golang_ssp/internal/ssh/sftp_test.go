@@ -0,0 +1,97 @@
+package ssh
+
+import (
+	"bytes"
+	"golang_ssp/golang_ssp/internal/config"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ssh3 "github.com/gliderlabs/ssh"
+	"github.com/pkg/sftp"
+)
+
+// startSFTPServer 启动带 sftp 子系统的测试服务器
+func startSFTPServer(t *testing.T) uint16 {
+	return startTestServer(t, nil, func(srv *ssh3.Server) error {
+		srv.SubsystemHandlers = map[string]ssh3.SubsystemHandler{
+			"sftp": func(s ssh3.Session) {
+				server, err := sftp.NewServer(s)
+				if err != nil {
+					return
+				}
+				server.Serve()
+				server.Close()
+			},
+		}
+		return nil
+	})
+}
+
+func TestSFTPShell(t *testing.T) {
+	port := startSFTPServer(t)
+
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "1234"}
+	client, err := Dial(cfg, nil)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	defer client.Close()
+
+	c, err := sftp.NewClient(client)
+	if err != nil {
+		t.Fatalf("Failed to open sftp: %v", err)
+	}
+	defer c.Close()
+
+	// 测试服务器直接使用本地文件系统
+	localDir := t.TempDir()
+	remoteDir := t.TempDir()
+	os.WriteFile(filepath.Join(localDir, "a.txt"), []byte("hello sftp"), 0640)
+
+	sh, err := newSFTPShell(c)
+	if err != nil {
+		t.Fatalf("Failed to create sftp shell: %v", err)
+	}
+	var out bytes.Buffer
+	sh.out = &out
+	sh.localDir = localDir
+
+	script := []string{
+		"cd " + remoteDir,
+		"mkdir sub",
+		"put a.txt sub",
+		"ls sub",
+		"get sub/a.txt b.txt",
+		"rm sub/a.txt",
+		"rmdir sub",
+		"exit",
+	}
+	if err := sh.run(lineReader(script)); err != nil {
+		t.Fatalf("Failed to run sftp shell: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "a.txt") {
+		t.Errorf("Expected ls output to contain a.txt, got:\n%s", out.String())
+	}
+	content, err := os.ReadFile(filepath.Join(localDir, "b.txt"))
+	if err != nil || string(content) != "hello sftp" {
+		t.Errorf("Expected downloaded content 'hello sftp', got %q (%v)", content, err)
+	}
+	if _, err := os.Stat(filepath.Join(remoteDir, "sub")); !os.IsNotExist(err) {
+		t.Errorf("Expected remote directory to be removed, got %v", err)
+	}
+}
+
+func lineReader(lines []string) func() (string, error) {
+	return func() (string, error) {
+		if len(lines) == 0 {
+			return "", io.EOF
+		}
+		line := lines[0]
+		lines = lines[1:]
+		return line, nil
+	}
+}
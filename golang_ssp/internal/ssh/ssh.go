@@ -1,95 +1,312 @@
 package ssh
 
 import (
-	"bytes"
+	"errors"
 	"fmt"
+	"golang_ssp/golang_ssp/internal/audit"
 	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/internal/prompt"
+	"golang_ssp/golang_ssp/internal/record"
 	"os"
 	"os/exec"
+	"os/signal"
+	"slices"
+	"strconv"
 	"syscall"
+
+	gossh "golang.org/x/crypto/ssh"
 )
 
 func Login(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string, cmd string) {
+	entry := audit.Begin(cfg, cmd)
 
-	ret := checkConnection(cfg)
-	if !ret {
-		panic("Connection failed")
-	} else {
-		updateConfigs(cfg, cfgs, configPath)
+	client, changed, err := connectRetry(cfg, cfgs)
+	if err != nil {
+		entry.Fail(err)
+		printConnectError(cfg, err)
+		os.Exit(1)
+	}
+	updateConfigs(cfg, cfgs, configPath)
+	if changed {
+		fmt.Printf("Password of %s@%s updated in cache\n", cfg.User, cfg.Host)
 	}
 
-	binary, err := exec.LookPath("sshpass")
+	recording := cmd == "ssh" && record.Enabled(cfg)
+	if Backend == BackendSSHPass && cfg.ProxyJump != "" {
+		fmt.Println("sshpass backend does not support ProxyJump, using native client")
+	} else if Backend == BackendSSHPass && recording {
+		fmt.Println("sshpass backend does not support session recording, using native client")
+	} else if Backend == BackendSSHPass {
+		client.Close()
+		entry.Handoff()
+		loginWithSSHPass(cfg, cfgs, cmd)
+		return
+	}
+
+	// 登录的同时启动保存的端口转发
+	fw := startForwards(client, cfg.Forwards)
+	unregister := func() {}
+	if len(fw.active) > 0 {
+		unregister = registerTunnels(cfg.Host, fw.active)
+	}
+
+	if cmd == "sftp" {
+		err := SFTPShell(client)
+		unregister()
+		client.Close()
+		if err != nil {
+			entry.Finish(1, err)
+			fmt.Printf("Error running sftp: %v\n", err)
+			os.Exit(1)
+		}
+		entry.Finish(0, nil)
+		return
+	}
+
+	var rec *record.Recorder
+	if recording {
+		rec, err = record.New(record.Dir(), cfg)
+		if err != nil {
+			fmt.Printf("Warning: session recording disabled: %v\n", err)
+		}
+	}
+
+	code, err := Shell(client, rec)
+	unregister()
+	client.Close()
+	entry.Finish(code, err)
+	if rec != nil {
+		rec.Close()
+		fmt.Printf("Session recorded to %s\n", rec.Path)
+	}
 	if err != nil {
-		fmt.Printf("Error looking up sshpass: %v\n", err)
+		fmt.Printf("Error running shell: %v\n", err)
+	}
+	os.Exit(code)
+}
+
+func loginWithSSHPass(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, cmd string) {
+	// 缓存中只有主机密钥指纹, 重新获取公钥并校验后写入临时 known_hosts, 让 ssh 严格校验同一个密钥,
+	// 不使用也不修改用户的 known_hosts
+	knownHosts, err := writeKnownHosts(cfg, cfgs)
+	if err != nil {
+		var mismatch *HostKeyMismatchError
+		if errors.As(err, &mismatch) {
+			fmt.Print(mismatch.Warning())
+		}
+		fmt.Printf("Error verifying host key of %s: %v\n", cfg.Host, err)
 		os.Exit(1)
 	}
+	hostKeyOpts := []string{"-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile=" + knownHosts,
+		"-o", "GlobalKnownHostsFile=/dev/null", "-o", "HostKeyAlias=" + cfg.Host, "-o", "LogLevel=ERROR"}
 
-	args := []string{"sshpass", "-p", cfg.Password, cmd, "-p", cfg.Port, fmt.Sprintf("%s@%s", cfg.User, cfg.Hostname)}
+	portOpt := "-p"
 	if cmd == "sftp" {
-		args = []string{"sshpass", "-p", cfg.Password, cmd, "-P", cfg.Port, fmt.Sprintf("%s@%s", cfg.User, cfg.Hostname)}
+		portOpt = "-P"
+	}
+	args := append([]string{cmd, portOpt, strconv.Itoa(int(cfg.PortOrDefault()))}, hostKeyOpts...)
+	if cfg.IdentityFile != "" {
+		args = append(args, "-i", config.AbsPath(cfg.IdentityFile))
+	}
+	if cmd == "ssh" {
+		for _, f := range cfg.Forwards {
+			args = append(args, "-"+f.Type, f.Value())
+		}
+	}
+	args = append(args, fmt.Sprintf("%s@%s", cfg.User, cfg.Hostname))
+
+	// 没有密码时 (私钥或 ssh-agent 认证) 不需要 sshpass
+	bin := cmd
+	env := os.Environ()
+	if cfg.Password != "" {
+		if _, err := exec.LookPath("sshpass"); err == nil {
+			bin = "sshpass"
+			args = append([]string{"sshpass", "-p", cfg.Password}, args...)
+		} else if self, err := os.Executable(); err == nil {
+			// 没有安装 sshpass 时把 ssp 自身作为 SSH_ASKPASS 程序, 从缓存中读取密码
+			env = append(env, "SSH_ASKPASS="+self, "SSH_ASKPASS_REQUIRE=force")
+		}
 	}
 
-	fmt.Println(args)
-	err = syscall.Exec(binary, args, os.Environ())
+	binary, err := exec.LookPath(bin)
 	if err != nil {
-		fmt.Printf("Error executing sshpass: %v\n", err)
+		os.Remove(knownHosts)
+		fmt.Printf("Error looking up %s: %v\n", bin, err)
 		os.Exit(1)
 	}
-	fmt.Println("Successfully logged in!")
-}
 
-func updateConfigs(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string) {
-	// 增加登录次数&时间
-	cfg.Increase()
-
-	isExist := false
-	for i, c := range *cfgs {
-		if c.Host == cfg.Host {
-			(*cfgs)[i].Update(cfg)
-			isExist = true
-			break
+	// 不直接 exec, 等待 ssh 退出后删除临时 known_hosts; 终端的 Ctrl-C 等信号交给 ssh 处理
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGQUIT, syscall.SIGHUP)
+	c := &exec.Cmd{Path: binary, Args: args, Env: env, Stdin: os.Stdin, Stdout: os.Stdout, Stderr: os.Stderr}
+	err = c.Run()
+	signal.Stop(signals)
+	os.Remove(knownHosts)
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code := exitErr.ExitCode()
+		if code < 0 {
+			code = 255
 		}
+		os.Exit(code)
 	}
-	if !isExist {
-		*cfgs = append(*cfgs, *cfg)
+	if err != nil {
+		fmt.Printf("Error executing %s: %v\n", bin, err)
+		os.Exit(1)
 	}
+	os.Exit(0)
+}
 
-	config.SortConfigs(cfgs)
-	if err := config.WriteConfig(configPath, *cfgs); err != nil {
+func updateConfigs(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string) {
+	latest, err := config.UpdateConfig(configPath, func(latest *[]config.SSHConfig) error {
+		// 本次连接中新记录的跳板机主机密钥
+		for i := range *latest {
+			for _, c := range *cfgs {
+				if (*latest)[i].Key() == c.Key() && (*latest)[i].HostKey == "" {
+					(*latest)[i].HostKey = c.HostKey
+				}
+			}
+		}
+
+		// 增加登录次数&时间, 以文件中最新的条目为准, 同时登录的多个 ssp 都能计数,
+		// 读取缓存之后其他 ssp 对这个条目的修改也不会被覆盖; 同一个主机的不同账号是不同的条目
+		var orig *config.SSHConfig
+		for i := range *cfgs {
+			if (*cfgs)[i].Key() == cfg.Key() {
+				orig = &(*cfgs)[i]
+				break
+			}
+		}
+		for i := range *latest {
+			if (*latest)[i].Key() == cfg.Key() {
+				if orig != nil {
+					applyLoginChanges(&(*latest)[i], orig, cfg)
+				} else {
+					(*latest)[i].Update(cfg)
+				}
+				(*latest)[i].Increase()
+				*cfg = (*latest)[i]
+				return nil
+			}
+		}
+		cfg.Increase()
+		*latest = append(*latest, *cfg)
+		return nil
+	})
+	if err != nil {
 		fmt.Println("Error writing config:", err)
+		return
 	}
+	*cfgs = *latest
 }
 
-func checkConnection(cfg *config.SSHConfig) bool {
-	// 删除 known_hosts 记录
-	if cfg.Port == "" {
-		cfg.Port = "22"
+// applyLoginChanges 只把本次登录修改的字段写到锁内重新读取的条目 latest 上: 重新输入的密码、
+// 首次记录的主机密钥, 以及 -i/-auth/-J/-L/-R/-D/-record 覆盖的值; orig 是读取缓存时的条目
+func applyLoginChanges(latest, orig, cfg *config.SSHConfig) {
+	if cfg.Password != orig.Password {
+		latest.Password = cfg.Password
+	}
+	if orig.HostKey == "" && latest.HostKey == "" {
+		latest.HostKey = cfg.HostKey
+	}
+	if cfg.IdentityFile != orig.IdentityFile {
+		latest.IdentityFile = cfg.IdentityFile
 	}
+	if cfg.AuthMethods != orig.AuthMethods {
+		latest.AuthMethods = cfg.AuthMethods
+	}
+	if cfg.ProxyJump != orig.ProxyJump {
+		latest.ProxyJump = cfg.ProxyJump
+	}
+	if cfg.Record != orig.Record {
+		latest.Record = cfg.Record
+	}
+	for _, f := range cfg.Forwards {
+		if !slices.Contains(orig.Forwards, f) {
+			latest.AddForward(f)
+		}
+	}
+}
 
-	kownhost := cfg.Hostname
+// connect 尝试连接测试, 成功后返回可以直接使用的连接
+func connect(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (*gossh.Client, error) {
+	client, err := Dial(cfg, cfgs)
+	if err != nil {
+		fmt.Printf("Connection test failed: %v\n", err)
+		return nil, err
+	}
 
-	if cfg.Port != "22" {
-		kownhost = "[" + cfg.Hostname + "]:" + cfg.Port
+	fmt.Println("Connection test passed, proceeding with login...")
+	return client, nil
+}
+
+// PasswordRetries 目标主机认证失败时最多重新输入密码的次数
+var PasswordRetries = 3
+
+// ReadPassword 读取新密码, 不回显; 标准输入不是终端时返回错误, 不再询问
+var ReadPassword = func(label string) (string, error) {
+	p := prompt.New()
+	if !p.Interactive() {
+		return "", errors.New("stdin is not a terminal")
 	}
+	return p.Secret(label, nil)
+}
 
-	execCmd := exec.Command("ssh-keygen", "-R", kownhost)
-	var testErr bytes.Buffer
+// connectRetry 目标主机认证失败时重新输入密码再连接, 网络错误和跳板机认证失败不重试
+// 返回的 changed 表示 cfg.Password 已经修改, 需要保存到缓存
+func connectRetry(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) (client *gossh.Client, changed bool, err error) {
+	client, err = connect(cfg, cfgs)
+	for i := 1; i <= PasswordRetries && err != nil && canRetryPassword(cfg, err); i++ {
+		password, readErr := ReadPassword(fmt.Sprintf("Password for %s@%s (%d/%d, empty to give up)", cfg.User, cfg.Host, i, PasswordRetries))
+		if readErr != nil || password == "" {
+			break
+		}
+		old := cfg.Password
+		cfg.Password = password
+		client, err = connect(cfg, cfgs)
+		if err != nil {
+			cfg.Password = old
+		} else {
+			changed = true
+		}
+	}
+	return client, changed, err
+}
 
-	execCmd.Stderr = &testErr
-	if err := execCmd.Run(); err != nil {
-		fmt.Printf("ssh-keygen remove failed: %v, stderr: \n\n%s\n", err, testErr.String())
+// canRetryPassword 只有目标主机本身认证失败, 并且允许密码认证时才询问新密码
+func canRetryPassword(cfg *config.SSHConfig, err error) bool {
+	var authErr *AuthError
+	if !errors.As(err, &authErr) || authErr.key != cfg.Key() {
 		return false
 	}
+	methods, err := config.ParseAuthMethods(cfg.AuthMethods)
+	return err == nil && (slices.Contains(methods, config.AuthPassword) || slices.Contains(methods, config.AuthKeyboardInteractive))
+}
 
-	// 尝试连接测试
-	testCmd := exec.Command("sshpass", "-p", cfg.Password, "ssh", "-p", cfg.Port, fmt.Sprintf("%s@%s", cfg.User, cfg.Hostname), "true")
-	testCmd.Stderr = &testErr
+// printConnectError 区分主机密钥变化、认证失败和网络错误, 给出对应的提示
+func printConnectError(cfg *config.SSHConfig, err error) {
+	var mismatch *HostKeyMismatchError
+	var authErr *AuthError
+	switch {
+	case errors.As(err, &mismatch):
+		fmt.Print(mismatch.Warning())
+	case errors.As(err, &authErr) && authErr.key != cfg.Key():
+		fmt.Printf("Authentication to jump host %s failed, fix it with: ssp %s@%s\n", authErr.Host, authErr.User, authErr.Host)
+	case errors.As(err, &authErr):
+		fmt.Printf("Authentication failed for %s@%s, the cached entry is not changed\n", cfg.User, cfg.Host)
+	case isTimeout(err):
+		fmt.Printf("Connection to %s timed out, check the network or the jump host\n", cfg.Address())
+	default:
+		fmt.Printf("Cannot connect to %s: %v\n", cfg.Address(), err)
+	}
+}
 
-	if err := testCmd.Run(); err != nil {
-		fmt.Printf("Connection test failed: %v, stderr: \n\n%s\n", err, testErr.String())
+func checkConnection(cfg *config.SSHConfig, cfgs *[]config.SSHConfig) bool {
+	client, err := connect(cfg, cfgs)
+	if err != nil {
 		return false
 	}
-
-	fmt.Println("Connection test passed, proceeding with login...")
+	client.Close()
 	return true
 }
@@ -2,50 +2,168 @@ package ssh
 
 import (
 	"golang_ssp/golang_ssp/internal/config"
-	"log"
+	"net"
 	"testing"
+	"time"
 
 	ssh3 "github.com/gliderlabs/ssh"
 )
 
+// startTestServer 启动本地 sshd.Server, 用户 test 密码 1234, 返回监听端口
+func startTestServer(t *testing.T, handler ssh3.Handler, options ...ssh3.Option) uint16 {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	srv := &ssh3.Server{Handler: handler}
+	srv.SetOption(ssh3.PasswordAuth(func(ctx ssh3.Context, password string) bool {
+		return ctx.User() == "test" && password == "1234" // 用户名和密码验证
+	}))
+	for _, option := range options {
+		srv.SetOption(option)
+	}
+
+	go srv.Serve(l)
+	t.Cleanup(func() { srv.Close() })
+
+	return uint16(l.Addr().(*net.TCPAddr).Port)
+}
+
 func TestCheckConnection(t *testing.T) {
 	// 启动 sshd.Server
-	go func() {
-		ssh3.Handle(func(s ssh3.Session) {
-			s.Write([]byte("Welcome to local SSH server!\n"))
-			s.Exit(0)
-		})
-
-		log.Println("Starting SSH server on 127.0.0.1:2222...")
-		err := ssh3.ListenAndServe("127.0.0.1:2222", nil, ssh3.PasswordAuth(func(ctx ssh3.Context, password string) bool {
-			return ctx.User() == "test" && password == "1234" // 用户名和密码验证
-		}))
-		if err != nil {
-			log.Fatal(err)
-		}
+	port := startTestServer(t, func(s ssh3.Session) {
+		s.Write([]byte("Welcome to local SSH server!\n"))
+		s.Exit(0)
+	})
 
-	}()
-	// time.Sleep(2 * time.Second)
 	// 测试
 	// 登录正常
 	cfg := &config.SSHConfig{
 		Host:          "test",
 		Hostname:      "127.0.0.1",
 		User:          "test",
-		Port:          "2222",
+		Port:          port,
 		Password:      "1234",
-		LoginTimes:    "0",
-		LastLoginTime: "2023-07-01T12:00:00",
+		LoginTimes:    0,
+		LastLoginTime: time.Date(2023, 7, 1, 12, 0, 0, 0, time.UTC),
 	}
 
-	if !checkConnection(cfg) {
+	if !checkConnection(cfg, nil) {
 		t.Fatalf("test Connection right failed")
 	}
 	// 登录异常
 
 	cfg.Password = "12345"
 
-	if checkConnection(cfg) {
+	if checkConnection(cfg, nil) {
 		t.Fatalf("test Connection wrong failed")
 	}
 }
+
+func TestUpdateConfigsAccounts(t *testing.T) {
+	configPath := t.TempDir() + "/config_cache"
+	root := config.SSHConfig{Host: "app", Hostname: "10.0.0.5", User: "root", Port: 22, Password: "r", LoginTimes: 5}
+	if err := config.WriteConfig(configPath, []config.SSHConfig{root}); err != nil {
+		t.Fatalf("WriteConfig: %v", err)
+	}
+	cfgs := []config.SSHConfig{root}
+
+	// 同一个主机的另一个账号是新的条目, 不覆盖已有的账号
+	deploy := config.SSHConfig{Host: "app", Hostname: "10.0.0.5", User: "deploy", Port: 22, Password: "d"}
+	updateConfigs(&deploy, &cfgs, configPath)
+	updateConfigs(&root, &cfgs, configPath)
+
+	if len(cfgs) != 2 {
+		t.Fatalf("Expected 2 accounts, got %v", cfgs)
+	}
+	for _, c := range cfgs {
+		switch {
+		case c.User == "root" && (c.Password != "r" || c.LoginTimes != 6):
+			t.Errorf("Expected root with 6 logins, got %v", c)
+		case c.User == "deploy" && (c.Password != "d" || c.LoginTimes != 1):
+			t.Errorf("Expected deploy with 1 login, got %v", c)
+		}
+	}
+}
+
+func TestUpdateConfigsKeepsEdits(t *testing.T) {
+	configPath := t.TempDir() + "/config_cache"
+	config.WriteConfig(configPath, []config.SSHConfig{{Host: "app", Hostname: "10.0.0.5", User: "root", Port: 22, Password: "r", LoginTimes: 5}})
+	cfgs, err := config.ReadConfig(configPath)
+	if err != nil {
+		t.Fatalf("ReadConfig: %v", err)
+	}
+	cfg := (*cfgs)[0]
+
+	// 登录期间另一个 ssp 修改了这个条目
+	config.UpdateConfig(configPath, func(latest *[]config.SSHConfig) error {
+		(*latest)[0].AddTags("env=prod")
+		(*latest)[0].IdentityFile = "~/.ssh/app"
+		(*latest)[0].Password = "changed"
+		return nil
+	})
+
+	// 本次登录首次记录主机密钥, 并通过 -J 指定了跳板机
+	cfg.HostKey = "SHA256:abc"
+	cfg.ProxyJump = "bastion"
+	updateConfigs(&cfg, cfgs, configPath)
+
+	saved, _ := config.ReadConfig(configPath)
+	c := (*saved)[0]
+	if len(c.Tags) != 1 || c.IdentityFile != "~/.ssh/app" || c.Password != "changed" {
+		t.Errorf("Expected edits made during the login to be kept, got %v", c)
+	}
+	if c.HostKey != "SHA256:abc" || c.ProxyJump != "bastion" || c.LoginTimes != 6 {
+		t.Errorf("Expected login changes to be saved, got %v", c)
+	}
+}
+
+func TestConnectRetry(t *testing.T) {
+	port := startTestServer(t, func(s ssh3.Session) { s.Exit(0) })
+
+	var prompts []string
+	answers := []string{"bad", "1234"}
+	oldRead := ReadPassword
+	ReadPassword = func(prompt string) (string, error) {
+		prompts = append(prompts, prompt)
+		answer := answers[0]
+		answers = answers[1:]
+		return answer, nil
+	}
+	defer func() { ReadPassword = oldRead }()
+
+	// 缓存的密码过期, 第二次输入正确的密码
+	cfg := &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "stale"}
+	client, changed, err := connectRetry(cfg, nil)
+	if err != nil {
+		t.Fatalf("Expected login after retry, got %v", err)
+	}
+	client.Close()
+	if !changed || cfg.Password != "1234" || len(prompts) != 2 {
+		t.Errorf("Expected password 1234 after 2 prompts, got %q changed=%v prompts=%v", cfg.Password, changed, prompts)
+	}
+
+	// 网络错误不询问密码
+	prompts = nil
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := uint16(l.Addr().(*net.TCPAddr).Port)
+	l.Close()
+	cfg = &config.SSHConfig{Host: "closed", Hostname: "127.0.0.1", User: "test", Port: closed, Password: "stale"}
+	if _, _, err := connectRetry(cfg, nil); err == nil || isAuthError(err) || len(prompts) != 0 {
+		t.Errorf("Expected network error without prompt, got %v prompts=%v", err, prompts)
+	}
+
+	// 只允许私钥认证时不询问密码
+	cfg = &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "stale", AuthMethods: "key"}
+	if _, _, err := connectRetry(cfg, nil); !isAuthError(err) || len(prompts) != 0 {
+		t.Errorf("Expected auth error without prompt, got %v prompts=%v", err, prompts)
+	}
+
+	// 重试次数用完后保留原来的密码
+	answers = []string{"a", "b", "c", "d"}
+	cfg = &config.SSHConfig{Host: "test", Hostname: "127.0.0.1", User: "test", Port: port, Password: "stale"}
+	if _, changed, err := connectRetry(cfg, nil); !isAuthError(err) || changed || cfg.Password != "stale" || len(prompts) != PasswordRetries {
+		t.Errorf("Expected auth error after %d prompts, got %v changed=%v password=%q prompts=%v", PasswordRetries, err, changed, cfg.Password, prompts)
+	}
+}
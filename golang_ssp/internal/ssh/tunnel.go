@@ -0,0 +1,133 @@
+package ssh
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// TunnelDir 记录正在运行的端口转发, 每个 ssp 进程一个文件
+var TunnelDir = "~/.ssh/ssp_tunnels"
+
+// TunnelRecord 一个 ssp 进程中正在运行的端口转发
+type TunnelRecord struct {
+	Pid      int      `json:"pid"`
+	Host     string   `json:"host"`
+	Forwards []string `json:"forwards"`
+	Started  string   `json:"started"`
+}
+
+// registerTunnels 记录当前进程的端口转发, 返回的函数用于删除记录
+func registerTunnels(host string, forwards []config.Forward) func() {
+	dir := config.AbsPath(TunnelDir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		fmt.Printf("Warning: record tunnels failed: %v\n", err)
+		return func() {}
+	}
+
+	record := TunnelRecord{Pid: os.Getpid(), Host: host, Started: time.Now().Format(config.TIMEFORMAT)}
+	for _, f := range forwards {
+		record.Forwards = append(record.Forwards, f.Spec())
+	}
+	data, _ := json.Marshal(record)
+
+	path := filepath.Join(dir, strconv.Itoa(record.Pid)+".json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		fmt.Printf("Warning: record tunnels failed: %v\n", err)
+		return func() {}
+	}
+	return func() { os.Remove(path) }
+}
+
+// ActiveTunnels 返回正在运行的端口转发, 顺便清理已经退出的进程留下的记录
+func ActiveTunnels() ([]TunnelRecord, error) {
+	dir := config.AbsPath(TunnelDir)
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var records []TunnelRecord
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var record TunnelRecord
+		if err := json.Unmarshal(data, &record); err != nil || !processAlive(record.Pid) {
+			os.Remove(file)
+			continue
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// ListTunnels 打印正在运行的端口转发
+func ListTunnels() {
+	records, err := ActiveTunnels()
+	if err != nil {
+		fmt.Printf("Error listing tunnels: %v\n", err)
+		return
+	}
+	if len(records) == 0 {
+		fmt.Println("No active tunnels")
+		return
+	}
+	for _, r := range records {
+		fmt.Printf("%-8d%-25s%-22s%s\n", r.Pid, r.Host, r.Started, strings.Join(r.Forwards, ", "))
+	}
+}
+
+// Tunnel 只启动保存的端口转发, 不打开 shell, 直到 Ctrl-C 或连接断开
+func Tunnel(cfg *config.SSHConfig, cfgs *[]config.SSHConfig, configPath string) error {
+	if len(cfg.Forwards) == 0 {
+		return fmt.Errorf("no forwards saved for host %s, add one with -L/-R/-D", cfg.Host)
+	}
+
+	client, _, err := connectRetry(cfg, cfgs)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+	updateConfigs(cfg, cfgs, configPath)
+
+	fw := startForwards(client, cfg.Forwards)
+	defer fw.Close()
+	if len(fw.active) == 0 {
+		return errors.New("no forward started")
+	}
+	defer registerTunnels(cfg.Host, fw.active)()
+
+	for _, f := range fw.active {
+		fmt.Printf("Forwarding %s via %s\n", f.Spec(), cfg.Host)
+	}
+	fmt.Println("Press Ctrl-C to stop")
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sigs)
+
+	closed := make(chan error, 1)
+	go func() { closed <- client.Wait() }()
+
+	select {
+	case <-sigs:
+		return nil
+	case err := <-closed:
+		return fmt.Errorf("connection closed: %v", err)
+	}
+}
@@ -1,16 +1,24 @@
 package main
 
 import (
-	"bufio"
+	"errors"
 	"flag"
 	"fmt"
+	"golang_ssp/golang_ssp/internal/audit"
+	"golang_ssp/golang_ssp/internal/completion"
 	"golang_ssp/golang_ssp/internal/config"
+	"golang_ssp/golang_ssp/internal/picker"
+	"golang_ssp/golang_ssp/internal/prompt"
+	"golang_ssp/golang_ssp/internal/record"
 	"golang_ssp/golang_ssp/internal/ssh"
 	"golang_ssp/golang_ssp/pkg/logger"
+	"io"
 	"os"
 	"runtime/debug"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var cacheConfigPath = "~/.ssh/config_cache"
@@ -22,51 +30,279 @@ var (
 	// ssp -list
 	listOpt = flag.Bool("list", false, "List cached hosts")
 	delOpt  = flag.String("del", "", "Delete cached record by indes of -list ")
+	// ssp -encrypt / -decrypt
+	encryptOpt = flag.Bool("encrypt", false, "Encrypt cached passwords with a master passphrase")
+	decryptOpt = flag.Bool("decrypt", false, "Store cached passwords in plain text again")
+	// ssp -accept-key node1
+	acceptKeyOpt = flag.String("accept-key", "", "Trust the current host key of a cached host")
+	// ssp -sftp node1, 等同于 ssftp node1
+	sftpOpt = flag.Bool("sftp", false, "Open sftp shell instead of ssh")
+	// ssp -i ~/.ssh/id_ed25519 -auth key,password node1
+	identityOpt = flag.String("i", "", "Identity file (private key) for public key authentication")
+	authOpt     = flag.String("auth", "", "Auth methods in order, e.g. agent,key,password,keyboard-interactive")
+	// ssp -J bastion node1
+	jumpOpt = flag.String("J", "", "Jump host, Host of another cached entry")
+	// ssp -L 8080:localhost:80 -D 1080 node1
+	localForwardOpt   = &forwardList{typ: config.ForwardLocal}
+	remoteForwardOpt  = &forwardList{typ: config.ForwardRemote}
+	dynamicForwardOpt = &forwardList{typ: config.ForwardDynamic}
+	// ssp -tunnel node1 / ssp -tunnels
+	tunnelOpt  = flag.String("tunnel", "", "Start saved forwards of a cached host without a shell")
+	tunnelsOpt = flag.Bool("tunnels", false, "List active forwards")
+	// ssp -import [~/.ssh/config]
+	importOpt = flag.Bool("import", false, "Import hosts from OpenSSH config, default ~/.ssh/config")
+	// ssp -export [~/.ssh/ssp_config]
+	exportOpt = flag.Bool("export", false, "Export cached hosts as OpenSSH config for Include, default ~/.ssh/ssp_config")
+	// ssp -tag env=prod,role=db node1 node2 / ssp -untag env @role=db
+	tagOpt   = flag.String("tag", "", "Add comma separated tags to hosts, e.g. env=prod,role=db")
+	untagOpt = flag.String("untag", "", "Remove comma separated tags (or tag keys) from hosts")
+	// ssp -record on node1
+	recordOpt = flag.String("record", "", "Record interactive logins of the host as asciicast: on or off, saved for the host")
+	// ssp -backend sshpass
+	backendOpt = flag.String("backend", defaultBackend(), "Login backend: native or sshpass")
 )
 
+func init() {
+	flag.Var(localForwardOpt, "L", "Local forward [bind_address:]port:host:hostport, saved for the host")
+	flag.Var(remoteForwardOpt, "R", "Remote forward [bind_address:]port:host:hostport, saved for the host")
+	flag.Var(dynamicForwardOpt, "D", "Dynamic SOCKS5 forward [bind_address:]port, saved for the host")
+}
+
+// forwardList 可重复的 -L/-R/-D 参数
+type forwardList struct {
+	typ      string
+	forwards []config.Forward
+}
+
+func (l *forwardList) String() string {
+	var specs []string
+	for _, f := range l.forwards {
+		specs = append(specs, f.Spec())
+	}
+	return strings.Join(specs, " ")
+}
+
+func (l *forwardList) Set(value string) error {
+	f, err := config.ParseForward(l.typ, value)
+	if err != nil {
+		return err
+	}
+	l.forwards = append(l.forwards, f)
+	return nil
+}
+
+func defaultBackend() string {
+	if backend := os.Getenv("SSP_BACKEND"); backend != "" {
+		return backend
+	}
+	return ssh.BackendNative
+}
+
 func ParseArgs() (string, map[string]interface{}) {
 
 	flag.Usage = func() {
-		fmt.Fprintf(flag.CommandLine.Output(), "Usage of ssp/ssftp (depends on sshpaas):\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage of ssp/ssftp:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  Description:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), `    ssp could simplify ssh login that auto compleled info by finding and caching ssh record,
     all record cache in ~/.ssh/config_cache`)
-		fmt.Fprintf(flag.CommandLine.Output(), "\n\nssp/ssftp [options] [host]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "\n\nssp/ssftp [options] [host|user@hostname|index|@group]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "ssp <command> [options] [args], ssp <command> -h shows help of the command\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Commands:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  add [options] <host> [[user@]hostname]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Add a host without logging in (e.g., ssp add -port 2222 -password-stdin node1 root@10.0.0.1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  rm [-y] <host|index|@group>...\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Remove hosts, groups ask for confirmation (e.g., ssp rm node1 3, ssp rm @env=old)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  edit [options] [host|index]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Change fields of a cached host, without options edit the host or the whole cache in $EDITOR (e.g., ssp edit -port 2222 node1, ssp edit node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  show <host|index>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Show all cached fields of a host, the password is masked (e.g., ssp show node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  mv <host|index> <new-host>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Rename a cached host (e.g., ssp mv node1 db1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  list [tags]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     List cached hosts, optionally filtered by tag expression (e.g., ssp list env=prod,role=db,!legacy)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  exec [-j 10] [-timeout 30s] <host|@group>... -- <command>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Run command on selected hosts concurrently with prefixed output and exit code summary (e.g., ssp exec @env=prod -- uptime)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  cp [-r] [-p] [-q] <source>... <target>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Copy files like scp using cached credentials (e.g., ssp cp file.tar node1:/tmp/, ssp cp -r node1:/var/log/app .)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  check [-j 20] [-timeout 5s] [host|@group]...\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Check reachability, ssh banner, authentication and host key concurrently, results are saved for each host (e.g., ssp check @env=prod)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  history [-since 7d] [-until 2024-01-02] [-failed] [-n 50] [host]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Show login history with start/end time, tty and exit status, $SSP_HISTORY changes the file, off disables (e.g., ssp history -since 24h node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  replay [-speed 2] [-idle 2s] <file>\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Play back a recorded session at adjustable speed (e.g., ssp replay ~/.ssh/ssp_recordings/node1-20240101-120000.cast)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  completion bash|zsh|fish\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Print shell completion script, hosts are completed from the cache (e.g., source <(ssp completion bash))\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "Options:\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  -host string\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "     SSH host to connect (e.g., ssp -host node1)\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  -hostname string\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "     SSH hostname to connect (e.g., ssp -hostname 127.0.0.1)\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  -list\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "     List cached hosts (e.g., ssp -list)\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "  -del\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "     Delete cached record by indes of -list (e.g., ssp -del 0)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -list [tags]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Same as ssp list (e.g., ssp -list env=prod)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -del string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Same as ssp rm (e.g., ssp -del 0, ssp -del @env=old)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -tag string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Add tags to hosts or groups, same key replaces old value (e.g., ssp -tag env=prod,role=db node1 node2)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -untag string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Remove tags, a key removes all its values (e.g., ssp -untag env @role=db)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -encrypt\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Encrypt cached passwords with a master passphrase (e.g., ssp -encrypt)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -decrypt\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Store cached passwords in plain text again (e.g., ssp -decrypt)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -accept-key string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Trust the changed host key of a cached host (e.g., ssp -accept-key node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -sftp\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Open built-in sftp shell, same as ssftp (e.g., ssp -sftp node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -i string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Identity file saved for the host (e.g., ssp -i ~/.ssh/id_ed25519 node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -auth string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Auth methods in order, saved for the host (e.g., ssp -auth agent,key node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     default agent,key,password,keyboard-interactive, unavailable methods are skipped\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -J string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Jump host saved for the host, must be another cached host (e.g., ssp -J bastion node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -L/-R/-D string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Port forwards like ssh, saved for the host and started on every login (e.g., ssp -L 8080:localhost:80 -D 1080 node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -tunnel string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Start saved forwards of a cached host without a shell (e.g., ssp -tunnel node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -tunnels\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     List active forwards (e.g., ssp -tunnels)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -import [path]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Import hosts from OpenSSH config, adds new accounts and never overwrites cached ones (e.g., ssp -import ~/.ssh/config)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -export [path]\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Export cached hosts as OpenSSH config for Include, default ~/.ssh/ssp_config, - for stdout (e.g., ssp -export)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -record on|off\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Record interactive logins of the host to ~/.ssh/ssp_recordings, saved for the host, SSP_RECORD=1 records all hosts, SSP_RECORD_STDIN=1 also records keystrokes including typed passwords (e.g., ssp -record on node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  -backend string\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Login backend, native or sshpass, default $SSP_BACKEND or native (e.g., ssp -backend sshpass node1)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "Login:\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  (no arguments)\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Open fuzzy finder over cached hosts, type to filter, arrows to move, Enter to login (e.g., ssp)\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  index\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "     Use inde of '-list' reusult to login  (e.g., ssp 2, meaning use 2nd host in cache )\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  host/hostname\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "     Same as -host -hostname, but needn`t '-' (e.g., ssp node1 or ssp 127.0.0.1 )\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Same as -host -hostname, but needn`t '-' (e.g., ssp node1 or ssp 127.0.0.1 ), use -host for hosts named like a command, hosts with several accounts ask which one to use\n")
 		fmt.Fprintf(flag.CommandLine.Output(), "  user@hostname\n")
-		fmt.Fprintf(flag.CommandLine.Output(), "     Like ssh command (e.g., ssp root@127.0.0.1 )\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Like ssh command, logs in with the cached account of the user, hostname can also be a cached Host (e.g., ssp root@127.0.0.1, ssp deploy@node1 )\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "  @group\n")
+		fmt.Fprintf(flag.CommandLine.Output(), "     Hosts selected by tag expression, @* for all hosts, host patterns like web* also work (e.g., ssp @env=prod)\n")
 	}
 
 	flag.Parse()
 
 	data := map[string]interface{}{}
 
+	if *backendOpt != ssh.BackendNative && *backendOpt != ssh.BackendSSHPass {
+		fmt.Printf("Invalid backend %s. Expected native or sshpass\n", *backendOpt)
+		os.Exit(1)
+	}
+	ssh.Backend = *backendOpt
+
+	if _, err := config.ParseAuthMethods(*authOpt); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	if *recordOpt != "" && *recordOpt != "on" && *recordOpt != "off" {
+		fmt.Printf("Invalid record %s. Expected on or off\n", *recordOpt)
+		os.Exit(1)
+	}
+
+	// 子命令, 各自有自己的参数和帮助, 如 ssp add -h
+	subcommands := map[string]func([]string, map[string]interface{}) (string, map[string]interface{}){
+		"add":     parseAddArgs,
+		"rm":      parseRmArgs,
+		"edit":    parseEditArgs,
+		"show":    parseShowArgs,
+		"mv":      parseMvArgs,
+		"list":    parseListArgs,
+		"exec":    parseExecArgs,
+		"cp":      parseCopyArgs,
+		"check":   parseCheckArgs,
+		"history": parseHistoryArgs,
+		"replay":  parseReplayArgs,
+	}
+	if flag.NArg() > 0 {
+		if parse, ok := subcommands[flag.Arg(0)]; ok {
+			return parse(flag.Args()[1:], data)
+		}
+	}
+
+	// -list 和 -del 是 ssp list 和 ssp rm 的旧写法
 	if *listOpt {
+		return listArgs(flag.Args(), data)
+	}
+
+	if *tagOpt != "" || *untagOpt != "" {
+		model, value := "tag", *tagOpt
+		if *untagOpt != "" {
+			model, value = "untag", *untagOpt
+		}
+		tags, err := config.ParseTags(value)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if flag.NArg() == 0 {
+			fmt.Printf("Invalid format %s argument. Expected hosts or @group\n", model)
+			os.Exit(1)
+		}
 		data["config"] = &config.SSHConfig{}
-		return "list", data
+		data["tags"] = tags
+		data["selectors"] = parseSelectors(flag.Args())
+		return model, data
 	}
 
-	if *delOpt != "" {
+	if *tunnelsOpt {
+		data["config"] = &config.SSHConfig{}
+		return "tunnels", data
+	}
 
-		if !isInt(*delOpt) {
-			fmt.Println("Invalid format del argument. Expected number")
-			os.Exit(1)
+	if *tunnelOpt != "" {
+		data["config"] = &config.SSHConfig{Host: *tunnelOpt, Hostname: *tunnelOpt}
+		return "tunnel", data
+	}
+
+	if *importOpt {
+		data["config"] = &config.SSHConfig{}
+		data["path"] = "~/.ssh/config"
+		if flag.NArg() > 0 {
+			data["path"] = flag.Arg(0)
 		}
+		return "import", data
+	}
+
+	if *exportOpt {
 		data["config"] = &config.SSHConfig{}
-		data["index"] = *delOpt
-		return "del", data
+		data["path"] = "~/.ssh/ssp_config"
+		if flag.NArg() > 0 {
+			data["path"] = flag.Arg(0)
+		}
+		return "export", data
+	}
+
+	if *encryptOpt {
+		data["config"] = &config.SSHConfig{}
+		return "encrypt", data
+	}
+
+	if *decryptOpt {
+		data["config"] = &config.SSHConfig{}
+		return "decrypt", data
+	}
+
+	if *delOpt != "" {
+		return parseRmArgs([]string{*delOpt}, data)
+	}
+
+	if strings.HasPrefix(*acceptKeyOpt, "@") {
+		data["config"] = &config.SSHConfig{}
+		data["selectors"] = parseSelectors([]string{*acceptKeyOpt})
+		return "accept-key", data
+	}
+
+	if *acceptKeyOpt != "" {
+		data["config"] = &config.SSHConfig{Host: *acceptKeyOpt, Hostname: *acceptKeyOpt}
+		return "accept-key", data
 	}
 
 	if *hostOpt != "" {
@@ -81,14 +317,17 @@ func ParseArgs() (string, map[string]interface{}) {
 	// 检查是否有非标志参数
 	args := flag.Args()
 
-	if strings.Contains(os.Args[0], "sftp") {
-		fmt.Println(os.Args)
+	if strings.Contains(os.Args[0], "sftp") || *sftpOpt {
 		CMD = "sftp"
 	}
 
 	if len(args) > 0 {
 		// 解析非标志参数
-		if strings.Contains(args[0], "@") {
+		if strings.HasPrefix(args[0], "@") {
+			data["config"] = &config.SSHConfig{}
+			data["selectors"] = parseSelectors(args[:1])
+			return "pick", data
+		} else if strings.Contains(args[0], "@") {
 			parts := strings.Split(args[0], "@")
 			if len(parts) == 2 {
 				user := strings.TrimSpace(parts[0])
@@ -110,8 +349,241 @@ func ParseArgs() (string, map[string]interface{}) {
 			return "login", data
 		}
 	}
-	fmt.Println("Invalid number of arguments.")
-	panic("Invalid number of arguments.")
+
+	// 没有参数时打开模糊查找选择主机
+	data["config"] = &config.SSHConfig{}
+	return "pick", data
+}
+
+// parseExecArgs 解析 ssp exec [-j 10] [-timeout 30s] <selector>... -- <command>
+// 没有 -- 时第一个参数是选择器, 其余是命令
+func parseExecArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("exec", flag.ExitOnError)
+	workers := fs.Int("j", 10, "Number of hosts to run on concurrently")
+	timeout := fs.Duration("timeout", 0, "Timeout per host including connecting, e.g. 30s, 0 for none")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp exec [-j 10] [-timeout 30s] <host|@group>... -- <command>\n")
+		fmt.Fprintf(fs.Output(), "  Run command on all selected cached hosts concurrently (e.g., ssp exec @env=prod -- uptime)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	rest := fs.Args()
+	var selectors, command []string
+	if i := slices.Index(rest, "--"); i >= 0 {
+		selectors, command = rest[:i], rest[i+1:]
+	} else if len(rest) > 0 {
+		selectors, command = rest[:1], rest[1:]
+	}
+	if len(selectors) == 0 || len(command) == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *workers < 1 {
+		fmt.Println("Invalid -j, expected at least 1")
+		os.Exit(1)
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["selectors"] = parseSelectors(selectors)
+	data["command"] = strings.Join(command, " ")
+	data["options"] = ssh.ExecOptions{Workers: *workers, Timeout: *timeout}
+	return "exec", data
+}
+
+// parseCheckArgs 解析 ssp check [-j 20] [-timeout 5s] [host|@group]..., 不指定主机时检查所有缓存的主机
+func parseCheckArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	workers := fs.Int("j", 20, "Number of hosts to check concurrently")
+	timeout := fs.Duration("timeout", 5*time.Second, "Timeout per host including connecting and authentication")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp check [-j 20] [-timeout 5s] [host|@group]...\n")
+		fmt.Fprintf(fs.Output(), "  Check reachability, ssh banner, authentication and host key of cached hosts, all hosts by default (e.g., ssp check @env=prod)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if *workers < 1 {
+		fmt.Println("Invalid -j, expected at least 1")
+		os.Exit(1)
+	}
+	if *timeout <= 0 {
+		fmt.Println("Invalid -timeout, expected a positive duration")
+		os.Exit(1)
+	}
+
+	selectors := fs.Args()
+	if len(selectors) == 0 {
+		selectors = []string{"@*"}
+	}
+	data["config"] = &config.SSHConfig{}
+	data["selectors"] = parseSelectors(selectors)
+	data["options"] = ssh.CheckOptions{Workers: *workers, Timeout: *timeout}
+	return "check", data
+}
+
+// parseHistoryArgs 解析 ssp history [-since 7d] [-until 2024-01-02] [-failed] [-n 50] [host]
+func parseHistoryArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	since := fs.String("since", "", "Only logins after this time, e.g. 24h, 7d, 2024-01-02, 2024-01-02 15:04")
+	until := fs.String("until", "", "Only logins before this time, same format as -since")
+	failed := fs.Bool("failed", false, "Only failed logins and sessions with non-zero exit status")
+	limit := fs.Int("n", 50, "Show the last n entries, 0 for all")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp history [-since 7d] [-until 2024-01-02] [-failed] [-n 50] [host]\n")
+		fmt.Fprintf(fs.Output(), "  Show login history from ~/.ssh/ssp_history.jsonl, host may be a Host or HostName pattern (e.g., ssp history -since 24h node1)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	filter := &audit.Filter{Host: fs.Arg(0), Failed: *failed}
+	now := time.Now()
+	var err error
+	if *since != "" {
+		if filter.Since, err = audit.ParseTime(*since, now); err != nil {
+			fmt.Printf("Invalid -since: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *until != "" {
+		if filter.Until, err = audit.ParseTime(*until, now); err != nil {
+			fmt.Printf("Invalid -until: %v\n", err)
+			os.Exit(1)
+		}
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["filter"] = filter
+	data["limit"] = *limit
+	return "history", data
+}
+
+// parseReplayArgs 解析 ssp replay [-speed 2] [-idle 2s] <file>
+func parseReplayArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("replay", flag.ExitOnError)
+	speed := fs.Float64("speed", 1, "Playback speed, e.g. 2 for twice as fast")
+	idle := fs.Duration("idle", 0, "Limit pauses between outputs, e.g. 2s, 0 for no limit")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp replay [-speed 2] [-idle 2s] <file>\n")
+		fmt.Fprintf(fs.Output(), "  Play back a recorded session (e.g., ssp replay ~/.ssh/ssp_recordings/node1-20240101-120000.cast)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if *speed <= 0 {
+		fmt.Println("Invalid -speed, expected a positive number")
+		os.Exit(1)
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["path"] = fs.Arg(0)
+	data["speed"] = *speed
+	data["idle"] = *idle
+	return "replay", data
+}
+
+// parseCopyArgs 解析 ssp cp [-r] [-p] [-q] <source>... <target>, 与 scp 相同, 本地和一个缓存的主机之间复制
+func parseCopyArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("cp", flag.ExitOnError)
+	recursive := fs.Bool("r", false, "Recursively copy directories")
+	preserve := fs.Bool("p", false, "Preserve modes and modification times")
+	quiet := fs.Bool("q", false, "Do not show progress")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp cp [-r] [-p] [-q] <source>... <target>\n")
+		fmt.Fprintf(fs.Output(), "  Copy files like scp using cached credentials (e.g., ssp cp file.tar node1:/tmp/, ssp cp node1:/var/log/x .)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	paths := fs.Args()
+	var host string
+	var sources []string
+	var remoteSources, localSources int
+	for _, arg := range paths[:len(paths)-1] {
+		h, p, ok := ssh.SplitRemotePath(arg)
+		if ok {
+			if host != "" && h != host {
+				fmt.Println("Copying between two remote hosts is not supported")
+				os.Exit(1)
+			}
+			host = h
+			remoteSources++
+		} else {
+			localSources++
+		}
+		sources = append(sources, p)
+	}
+	targetHost, target, targetRemote := ssh.SplitRemotePath(paths[len(paths)-1])
+
+	upload := targetRemote && remoteSources == 0
+	download := !targetRemote && localSources == 0
+	if !upload && !download {
+		fmt.Println("Invalid cp arguments. Expected local files and a host:path target, or host:path sources and a local target")
+		os.Exit(1)
+	}
+	if upload {
+		host = targetHost
+	}
+
+	cfg := &config.SSHConfig{Host: host, Hostname: host}
+	if user, hostname, ok := strings.Cut(host, "@"); ok {
+		cfg = &config.SSHConfig{Host: hostname, Hostname: hostname, User: user}
+	}
+	data["config"] = cfg
+	data["sources"] = sources
+	data["target"] = target
+	data["upload"] = upload
+	data["options"] = ssh.CopyOptions{Recursive: *recursive, Preserve: *preserve, Quiet: *quiet}
+	return "cp", data
+}
+
+// parseSelectors 解析主机名或 @ 开头的分组, 格式错误时退出
+func parseSelectors(args []string) []*config.Selector {
+	var sels []*config.Selector
+	for _, arg := range args {
+		sel, err := config.ParseSelector(arg)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		sels = append(sels, sel)
+	}
+	return sels
+}
+
+// matchSelectors 主机是否属于任意一个选择器
+func matchSelectors(sels []*config.Selector, c *config.SSHConfig) bool {
+	for _, sel := range sels {
+		if sel.Match(c) {
+			return true
+		}
+	}
+	return false
+}
+
+// selectConfigs 返回选中的主机, 没有选中任何主机时退出
+func selectConfigs(cfgs []config.SSHConfig, sels []*config.Selector) []config.SSHConfig {
+	var selected []config.SSHConfig
+	for _, c := range cfgs {
+		if matchSelectors(sels, &c) {
+			selected = append(selected, c)
+		}
+	}
+	if len(selected) == 0 {
+		fmt.Println("No cached hosts match")
+		os.Exit(1)
+	}
+	return selected
 }
 
 func isInt(s string) bool {
@@ -122,76 +594,171 @@ func isInt(s string) bool {
 
 }
 
-func ReadInput(cfg *config.SSHConfig) *config.SSHConfig {
-	// fmt.Println(cfg)
-
+// ReadInput 询问缓存中没有的登录信息, 终端上密码不回显, 输入有误时提示并重新输入;
+// 标准输入不是终端时按行读取, 输入结束后使用默认值, 便于脚本使用
+func ReadInput(cfg *config.SSHConfig) (*config.SSHConfig, error) {
 	if cfg == nil {
 		cfg = &config.SSHConfig{}
 	}
-	reader := bufio.NewReader(os.Stdin)
+	p := prompt.New()
+	var err error
 
 	if cfg.Host == "" {
-		if cfg.Hostname != "" {
-			fmt.Print("Enter Host Like \"node1\" (default " + cfg.Hostname + "): ")
-		} else {
-			fmt.Print("Enter Host Like \"node1\": ")
+		cfg.Host, err = p.Line(`Enter Host Like "node1"`, cfg.Hostname, checkName("host"))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, err = p.Line(`Enter Hostname Like "127.0.0.1"`, "", checkName("hostname"))
+		if err != nil {
+			return nil, err
 		}
-		host, _ := reader.ReadString('\n')
-		cfg.Host = strings.TrimSpace(host)
+	}
+	if cfg.User == "" {
+		cfg.User, err = p.Line("Enter Username", "root", checkName("user"))
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		if cfg.Host == "" {
-			if cfg.Hostname != "" {
-				cfg.Host = cfg.Hostname
-			} else {
-				fmt.Println("Host cannot be empty.")
-				os.Exit(1)
+	for attempt := 1; cfg.Password == "" && cfg.IdentityFile == ""; attempt++ {
+		cfg.Password, err = p.Secret("Enter Password (empty to use ssh-agent or identity file)", nil)
+		if err != nil {
+			return nil, err
+		}
+		if cfg.Password == "" {
+			cfg.IdentityFile, err = p.Line(`Enter IdentityFile Like "~/.ssh/id_ed25519" (empty for none)`, "", checkIdentityFile)
+			if err != nil {
+				return nil, err
 			}
 		}
+		if cfg.Password != "" || cfg.IdentityFile != "" || os.Getenv("SSH_AUTH_SOCK") != "" {
+			break
+		}
+		err = errors.New("password cannot be empty without ssh-agent or identity file")
+		if !p.Retry(attempt, err) {
+			return nil, err
+		}
 	}
-	if cfg.Hostname == "" {
-		fmt.Print("Enter Hostname Like \"127.0.0.1\": ")
-		hostname, _ := reader.ReadString('\n')
-		cfg.Hostname = strings.TrimSpace(hostname)
 
-		if cfg.Hostname == "" {
-			fmt.Println("Hostname cannot be empty.")
-			os.Exit(1)
+	if cfg.Port == 0 {
+		input, err := p.Line("Enter Port", strconv.Itoa(config.DefaultPort), func(v string) error {
+			_, err := config.ParsePort(v)
+			return err
+		})
+		if err != nil {
+			return nil, err
 		}
+		cfg.Port, _ = config.ParsePort(input)
 	}
 
-	if cfg.User == "" {
-		fmt.Print("Enter Username (default \"root\") ")
-		user, _ := reader.ReadString('\n')
-		cfg.User = strings.TrimSpace(user)
+	return cfg, nil
+}
 
-		if cfg.User == "" {
-			cfg.User = "root"
+// checkName Host、HostName 和 User 不能为空, 也不能包含空白或 @
+func checkName(kind string) func(string) error {
+	return func(v string) error {
+		if v == "" {
+			return fmt.Errorf("%s cannot be empty", kind)
+		}
+		if strings.ContainsAny(v, " \t@") {
+			return fmt.Errorf("invalid %s %q", kind, v)
 		}
+		return nil
 	}
+}
 
-	if cfg.Password == "" {
-		fmt.Print("Enter Password : ")
-		password, _ := reader.ReadString('\n')
-		cfg.Password = strings.TrimSpace(password)
+func checkIdentityFile(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, err := os.Stat(config.AbsPath(path)); err != nil {
+		return fmt.Errorf("identity file %s: %w", path, err)
+	}
+	return nil
+}
 
-		if cfg.Password == "" {
-			fmt.Println("Hostname cannot be empty.")
-			os.Exit(1)
+// applyHostOpts 使用 -i/-auth/-J/-L/-R/-D 覆盖配置中的认证和转发信息, 登录成功后保存到缓存
+func applyHostOpts(cfg *config.SSHConfig) {
+	if *jumpOpt != "" {
+		cfg.ProxyJump = *jumpOpt
+	}
+	for _, list := range []*forwardList{localForwardOpt, remoteForwardOpt, dynamicForwardOpt} {
+		for _, f := range list.forwards {
+			cfg.AddForward(f)
 		}
 	}
+	if *identityOpt != "" {
+		cfg.IdentityFile = *identityOpt
+	}
+	if *authOpt != "" {
+		cfg.AuthMethods = *authOpt
+	}
+	if *recordOpt != "" {
+		cfg.Record = *recordOpt == "on"
+	}
+}
 
-	if cfg.Port == "" {
-		fmt.Print("Enter Port (default 22) ")
-		port, _ := reader.ReadString('\n')
-		cfg.Port = strings.TrimSpace(port)
+// askpass ssp 作为 SSH_ASKPASS 程序被 ssh 调用时, 参数为密码提示, 只向 stdout 输出缓存中的密码
+func askpass() {
+	if len(os.Args) != 2 {
+		return
+	}
+	prompt := os.Args[1]
+	if _, _, ok := config.ParseAskpassPrompt(prompt); !ok {
+		return
+	}
 
-		if cfg.Port == "" {
-			cfg.Port = "22"
-		}
+	cfgs, err := config.ReadConfig(cacheConfigPath)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "ssp askpass: %v\n", err)
+		os.Exit(1)
+	}
+	password, ok := config.LookupAskpass(*cfgs, prompt)
+	if !ok {
+		fmt.Fprintf(os.Stderr, "ssp askpass: no cached password for %q\n", prompt)
+		os.Exit(1)
 	}
+	fmt.Println(password)
+	os.Exit(0)
+}
 
-	return cfg
+// complete 处理 ssp completion <shell> 和补全脚本调用的 ssp __complete <已输入的参数...>
+// 在输出日志之前处理, 保证输出中只有脚本或候选
+func complete() {
+	if len(os.Args) < 2 {
+		return
+	}
+	if os.Args[1] == "completion" {
+		if len(os.Args) != 3 {
+			fmt.Println("Usage: ssp completion bash|zsh|fish")
+			os.Exit(1)
+		}
+		script, err := completion.Script(os.Args[2])
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		fmt.Print(script)
+		os.Exit(0)
+	}
+	if os.Args[1] != "__complete" {
+		return
+	}
+	logger.Logger.SetOutput(io.Discard)
 
+	flags := map[string]bool{}
+	flag.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags[f.Name] = !ok || !b.IsBoolFlag()
+	})
+	cfgs, _ := config.ReadHosts(cacheConfigPath)
+	c := &completion.Completer{Flags: flags, Hosts: cfgs}
+	for _, candidate := range c.Complete(os.Args[2:]) {
+		fmt.Println(candidate)
+	}
+	os.Exit(0)
 }
 
 func printPanic() {
@@ -205,10 +772,49 @@ func printPanic() {
 
 func main() {
 	defer printPanic()
+	askpass()
+	complete()
 	logger.Logger.Println("ssp start!")
 	model, data := ParseArgs()
 	inputCfg := data["config"].(*config.SSHConfig)
 
+	// history 和 replay 不读取缓存, 不需要升级缓存或输入主密码
+	switch model {
+	case "history":
+		path := audit.Path()
+		if path == "" {
+			fmt.Printf("Login history is disabled by %s=off\n", audit.Env)
+			os.Exit(1)
+		}
+		entries, err := audit.ReadFile(path, data["filter"].(*audit.Filter))
+		if err != nil {
+			fmt.Printf("Error reading history: %v\n", err)
+			os.Exit(1)
+		}
+		if limit := data["limit"].(int); limit > 0 && len(entries) > limit {
+			entries = entries[len(entries)-limit:]
+		}
+		if len(entries) == 0 {
+			fmt.Println("No login history found")
+			return
+		}
+		audit.Print(os.Stdout, entries)
+		return
+
+	case "replay":
+		if err := replay(data["path"].(string), data["speed"].(float64), data["idle"].(time.Duration)); err != nil {
+			fmt.Printf("Error replaying %s: %v\n", data["path"], err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 旧版本的缓存文件先备份再升级
+	if err := config.MigrateConfig(cacheConfigPath); err != nil {
+		fmt.Printf("Error migrating cache config: %v\n", err)
+		os.Exit(1)
+	}
+
 	cfgs, err := config.ReadConfig(cacheConfigPath)
 
 	if err != nil {
@@ -219,7 +825,40 @@ func main() {
 	switch model {
 	case "list":
 
-		config.ListConfigs(*cfgs)
+		if filter, ok := data["filter"].(string); ok {
+			sel, _ := config.ParseTagFilter(filter)
+			config.ListSelected(*cfgs, sel)
+		} else {
+			config.ListConfigs(*cfgs)
+		}
+
+	case "tag", "untag":
+		sels := data["selectors"].([]*config.Selector)
+		tags := data["tags"].([]string)
+		count := 0
+		_, err := config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+			for i := range *latest {
+				c := &(*latest)[i]
+				if !matchSelectors(sels, c) {
+					continue
+				}
+				if model == "tag" {
+					c.AddTags(tags...)
+				} else {
+					c.RemoveTags(tags...)
+				}
+				count++
+			}
+			if count == 0 {
+				return fmt.Errorf("no cached hosts match")
+			}
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error updating tags: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Updated tags of %d hosts\n", count)
 
 	case "login":
 		if inputCfg == nil {
@@ -227,17 +866,52 @@ func main() {
 			panic("Invalid number of arguments.")
 		}
 
-		cfg, err := config.GetSSHConfig(cfgs, inputCfg)
-		if err != nil {
-			// 获取不到配置
-			cfg = ReadInput(inputCfg)
-			if cfg == nil {
-				fmt.Printf("Error getting SSH config: %v\n", err)
-				panic("Invalid number of arguments.")
+		var cfg *config.SSHConfig
+		matches := config.FindSSHConfigs(*cfgs, inputCfg)
+		switch {
+		case len(matches) == 0:
+			// 获取不到配置, 指定了 -i 时不再询问密码; 其他参数在选定账号后统一应用
+			inputCfg.IdentityFile = *identityOpt
+			cfg, err = ReadInput(inputCfg)
+			if err != nil {
+				fmt.Printf("Error reading login info: %v\n", err)
+				os.Exit(1)
+			}
+			fmt.Printf("Login %s as %s@%s\n", cfg.Host, cfg.User, cfg.Address())
+		case len(matches) == 1:
+			cfg = &matches[0]
+		default:
+			// 同一个主机有多个账号时选择一个, 也可以用 user@host 直接指定
+			cfg, err = picker.Pick(matches)
+			if errors.Is(err, picker.ErrCancelled) {
+				return
+			}
+			if err != nil {
+				fmt.Printf("%d accounts found, login with user@host: %v\n", len(matches), err)
+				for _, c := range matches {
+					fmt.Printf("  %s@%s (%s)\n", c.User, c.Host, c.Address())
+				}
+				os.Exit(1)
 			}
-			fmt.Println(cfg)
 		}
 
+		applyHostOpts(cfg)
+		ssh.Login(cfg, cfgs, cacheConfigPath, CMD)
+	case "pick":
+		candidates := *cfgs
+		if sels, ok := data["selectors"].([]*config.Selector); ok {
+			candidates = selectConfigs(candidates, sels)
+		}
+		cfg, err := picker.Pick(candidates)
+		if errors.Is(err, picker.ErrCancelled) {
+			return
+		}
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		applyHostOpts(cfg)
 		ssh.Login(cfg, cfgs, cacheConfigPath, CMD)
 	case "index":
 
@@ -250,18 +924,186 @@ func main() {
 
 		cfg := (*cfgs)[index]
 
+		applyHostOpts(&cfg)
 		ssh.Login(&cfg, cfgs, cacheConfigPath, CMD)
 
-	case "del":
-		index, _ := strconv.Atoi(data["index"].(string))
-		if index < 0 || index >= len(*cfgs) {
-			fmt.Printf("Invalid index: out of range %d\n", index)
+	case "add":
+		addHost(inputCfg, data["fields"].(*hostFlags))
+
+	case "rm":
+		removeHosts(*cfgs, data["targets"].([]string), data["yes"].(bool))
+
+	case "edit":
+		if fields, ok := data["fields"].(*hostFlags); ok {
+			editHost(*cfgs, data["target"].(string), fields)
+		} else {
+			editInEditor(*cfgs, data["target"].(string))
+		}
+
+	case "show":
+		showHost(*cfgs, data["target"].(string))
+
+	case "mv":
+		moveHost(*cfgs, data["target"].(string), data["name"].(string))
+
+	case "exec":
+		targets := selectConfigs(*cfgs, data["selectors"].([]*config.Selector))
+		results := ssh.Exec(targets, cfgs, cacheConfigPath, data["command"].(string), data["options"].(ssh.ExecOptions), os.Stdout, os.Stderr)
+		if failed := ssh.PrintExecSummary(os.Stdout, results); failed > 0 {
 			os.Exit(1)
 		}
-		*cfgs = append((*cfgs)[:index], (*cfgs)[index+1:]...)
 
-		config.WriteConfig(cacheConfigPath, *cfgs)
+	case "check":
+		targets := selectConfigs(*cfgs, data["selectors"].([]*config.Selector))
+		results := ssh.Check(targets, cfgs, cacheConfigPath, data["options"].(ssh.CheckOptions))
+		if failed := ssh.PrintCheckSummary(os.Stdout, results); failed > 0 {
+			os.Exit(1)
+		}
 
+	case "cp":
+		target := inputCfg.Host
+		if inputCfg.User != "" {
+			target = inputCfg.User + "@" + target
+		}
+		cfg, err := lookupHost(*cfgs, target)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		applyHostOpts(cfg)
+		err = ssh.Copy(cfg, cfgs, cacheConfigPath, data["sources"].([]string), data["target"].(string), data["upload"].(bool), data["options"].(ssh.CopyOptions))
+		if err != nil {
+			fmt.Printf("Error copying files: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "tunnels":
+		ssh.ListTunnels()
+
+	case "tunnel":
+		cfg, err := lookupHost(*cfgs, inputCfg.Host)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		applyHostOpts(cfg)
+		if err := ssh.Tunnel(cfg, cfgs, cacheConfigPath); err != nil {
+			fmt.Printf("Error starting tunnels: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "import":
+		sshConfig, err := config.ParseOpenSSHConfig(data["path"].(string))
+		if err != nil {
+			fmt.Printf("Error reading OpenSSH config: %v\n", err)
+			os.Exit(1)
+		}
+		var added, updated int
+		_, err = config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+			added, updated = config.MergeConfigs(latest, sshConfig.Entries())
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error writing cache config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Imported %s: %d added, %d updated\n", data["path"], added, updated)
+
+	case "export":
+		path := data["path"].(string)
+		if path == "-" {
+			err = config.ExportOpenSSH(os.Stdout, *cfgs)
+		} else {
+			err = config.ExportOpenSSHFile(path, *cfgs)
+		}
+		if err != nil {
+			fmt.Printf("Error exporting OpenSSH config: %v\n", err)
+			os.Exit(1)
+		}
+		if path != "-" {
+			fmt.Printf("Exported %d hosts to %s\n", len(*cfgs), path)
+			fmt.Printf("Add \"Include %s\" to the top of ~/.ssh/config, and for password hosts:\n", path)
+			fmt.Println("  export SSH_ASKPASS=$(which ssp) SSH_ASKPASS_REQUIRE=force")
+		}
+
+	case "accept-key":
+		if sels, ok := data["selectors"].([]*config.Selector); ok {
+			failed := 0
+			for _, c := range selectConfigs(*cfgs, sels) {
+				if err := ssh.AcceptHostKey(&c, cfgs, cacheConfigPath); err != nil {
+					fmt.Printf("Error accepting host key of %s: %v\n", c.Host, err)
+					failed++
+				}
+			}
+			if failed > 0 {
+				os.Exit(1)
+			}
+			return
+		}
+		cfg, err := lookupHost(*cfgs, inputCfg.Host)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		if err := ssh.AcceptHostKey(cfg, cfgs, cacheConfigPath); err != nil {
+			fmt.Printf("Error accepting host key: %v\n", err)
+			os.Exit(1)
+		}
+
+	case "encrypt":
+		passphrase, err := config.PromptPassphrase("Enter new master passphrase", true)
+		if err != nil {
+			fmt.Printf("Error reading master passphrase: %v\n", err)
+			os.Exit(1)
+		}
+		// 先用原来的主口令读取, 再用新的主口令写入
+		latest, err := config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+			config.SetPassphrase(passphrase)
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error writing cache config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Encrypted %d cached passwords\n", countPasswords(*latest))
+
+	case "decrypt":
+		latest, err := config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+			config.SetPassphrase("")
+			return nil
+		})
+		if err != nil {
+			fmt.Printf("Error writing cache config: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Decrypted %d cached passwords\n", countPasswords(*latest))
+	}
+
+}
+
+// countPasswords 返回缓存的密码个数, 没有密码的主机 (私钥或 agent 认证) 不计入
+func countPasswords(cfgs []config.SSHConfig) int {
+	n := 0
+	for _, c := range cfgs {
+		if c.Password != "" {
+			n++
+		}
 	}
+	return n
+}
 
+// replay 播放录像, 结束后恢复终端属性
+func replay(path string, speed float64, idle time.Duration) error {
+	file, err := os.Open(config.AbsPath(path))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	header, err := record.Replay(file, os.Stdout, speed, idle)
+	fmt.Print("\x1b[0m\r\n")
+	if header != nil {
+		fmt.Printf("Replayed %s recorded at %s (%dx%d)\n", header.Title, time.Unix(header.Timestamp, 0).Format("2006-01-02 15:04:05"), header.Width, header.Height)
+	}
+	return err
 }
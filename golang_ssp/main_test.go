@@ -38,6 +38,81 @@ func TestParseArgs(t *testing.T) {
 			expectedCfg:   &config.SSHConfig{},
 			expectedModel: "index",
 		},
+		{
+			args:          []string{"@env=prod,role=db"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "pick",
+		},
+		{
+			args:          []string{"-del", "0"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "rm",
+		},
+		{
+			args:          []string{"add", "-port", "2222", "node1", "admin@10.0.0.1"},
+			expectedCfg:   &config.SSHConfig{Host: "node1", Hostname: "10.0.0.1", User: "admin"},
+			expectedModel: "add",
+		},
+		{
+			args:          []string{"rm", "-y", "node1", "@env=old"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "rm",
+		},
+		{
+			args:          []string{"edit", "-user", "admin", "node1"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "edit",
+		},
+		{
+			args:          []string{"edit", "node1"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "edit",
+		},
+		{
+			args:          []string{"edit"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "edit",
+		},
+		{
+			args:          []string{"show", "node1"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "show",
+		},
+		{
+			args:          []string{"mv", "node1", "db1"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "mv",
+		},
+		{
+			args:          []string{"list", "env=prod"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "list",
+		},
+		{
+			args:          []string{"exec", "-j", "2", "@env=prod", "--", "uptime", "-p"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "exec",
+		},
+		{
+			args:          []string{"cp", "-r", "dist", "root@node1:/tmp/"},
+			expectedCfg:   &config.SSHConfig{Host: "node1", Hostname: "node1", User: "root"},
+			expectedModel: "cp",
+		},
+		{
+			args:          []string{"check", "-timeout", "3s", "@env=prod"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "check",
+		},
+		{
+			args:          []string{"history", "-since", "7d", "node1"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "history",
+		},
+		{
+			args:          []string{"replay", "-speed", "2", "node1.cast"},
+			expectedCfg:   &config.SSHConfig{},
+			expectedModel: "replay",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -45,6 +120,7 @@ func TestParseArgs(t *testing.T) {
 		*listOpt = false
 		*hostnameOpt = ""
 		*hostOpt = ""
+		*delOpt = ""
 
 		// 模拟命令行参数
 		oldArgs := os.Args
@@ -77,3 +153,34 @@ func TestReadInput(*testing.T) {
 
 	ReadInput(&sshConfig)
 }
+
+func TestReadInputScript(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldStdin := os.Stdin
+	os.Stdin = r
+	defer func() { os.Stdin = oldStdin }()
+
+	// 非终端按行读取, 用户和端口使用默认值
+	w.WriteString("node1\n10.0.0.1\n\nsecret\n")
+	w.Close()
+	cfg, err := ReadInput(&config.SSHConfig{})
+	if err != nil {
+		t.Fatalf("ReadInput: %v", err)
+	}
+	expected := &config.SSHConfig{Host: "node1", Hostname: "10.0.0.1", User: "root", Password: "secret", Port: 22}
+	if !cfg.Equals(expected) {
+		t.Errorf("Expected %v, got %v", expected, cfg)
+	}
+
+	// 非法输入返回错误而不是退出
+	r, w, _ = os.Pipe()
+	os.Stdin = r
+	w.WriteString("node1\n10.0.0.1\nroot\nsecret\n70000\n")
+	w.Close()
+	if _, err := ReadInput(&config.SSHConfig{}); err == nil {
+		t.Errorf("Expected error for port 70000")
+	}
+}
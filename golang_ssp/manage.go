@@ -0,0 +1,710 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"golang_ssp/golang_ssp/internal/config"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// hostFlags ssp add 和 ssp edit 共用的主机字段参数, 只修改命令行中指定了的字段
+type hostFlags struct {
+	fs            *flag.FlagSet
+	hostname      string
+	user          string
+	port          string
+	password      string
+	passwordStdin bool
+	identity      string
+	auth          string
+	jump          string
+	tags          string
+	record        string
+}
+
+func newHostFlags(fs *flag.FlagSet) *hostFlags {
+	h := &hostFlags{fs: fs}
+	fs.StringVar(&h.hostname, "hostname", "", "Hostname or IP address")
+	fs.StringVar(&h.user, "user", "", "Login user")
+	fs.StringVar(&h.port, "port", "", "SSH port")
+	fs.StringVar(&h.password, "password", "", "Password, visible in the process list, prefer -password-stdin")
+	fs.BoolVar(&h.passwordStdin, "password-stdin", false, "Read the password from the first line of stdin")
+	fs.StringVar(&h.identity, "i", "", "Identity file (private key), empty to remove")
+	fs.StringVar(&h.auth, "auth", "", "Auth methods in order, e.g. agent,key,password, empty for default")
+	fs.StringVar(&h.jump, "J", "", "Jump host, Host of another cached entry, empty to remove")
+	fs.StringVar(&h.tags, "tags", "", "Comma separated tags replacing existing ones, empty to remove all")
+	fs.StringVar(&h.record, "record", "", "Record interactive logins: on or off")
+	return h
+}
+
+// set 返回命令行中指定了的参数
+func (h *hostFlags) set() map[string]bool {
+	set := map[string]bool{}
+	h.fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	return set
+}
+
+// validate 解析参数时检查格式, 此时不读取标准输入
+func (h *hostFlags) validate() error {
+	set := h.set()
+	if set["port"] {
+		if _, err := config.ParsePort(h.port); err != nil {
+			return err
+		}
+	}
+	if set["auth"] {
+		if _, err := config.ParseAuthMethods(h.auth); err != nil {
+			return err
+		}
+	}
+	if set["tags"] && strings.TrimSpace(h.tags) != "" {
+		if _, err := config.ParseTags(h.tags); err != nil {
+			return err
+		}
+	}
+	if set["record"] && h.record != "on" && h.record != "off" {
+		return fmt.Errorf("invalid record %s, expected on or off", h.record)
+	}
+	if set["password"] && h.passwordStdin {
+		return fmt.Errorf("-password and -password-stdin cannot be used together")
+	}
+	if set["hostname"] && strings.TrimSpace(h.hostname) == "" {
+		return fmt.Errorf("hostname cannot be empty")
+	}
+	if set["user"] && strings.TrimSpace(h.user) == "" {
+		return fmt.Errorf("user cannot be empty")
+	}
+	return nil
+}
+
+// apply 把指定了的字段写入 cfg, 使用 -password-stdin 时读取标准输入的第一行
+func (h *hostFlags) apply(cfg *config.SSHConfig) error {
+	set := h.set()
+	if set["hostname"] {
+		cfg.Hostname = strings.TrimSpace(h.hostname)
+	}
+	if set["user"] {
+		cfg.User = strings.TrimSpace(h.user)
+	}
+	if set["port"] {
+		cfg.Port, _ = config.ParsePort(h.port)
+	}
+	if set["password"] {
+		cfg.Password = h.password
+	}
+	if h.passwordStdin {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			return fmt.Errorf("read password from stdin: %w", err)
+		}
+		cfg.Password = strings.TrimRight(line, "\r\n")
+	}
+	if set["i"] {
+		cfg.IdentityFile = h.identity
+	}
+	if set["auth"] {
+		cfg.AuthMethods = h.auth
+	}
+	if set["J"] {
+		cfg.ProxyJump = h.jump
+	}
+	if set["tags"] {
+		cfg.Tags = nil
+		if tags, err := config.ParseTags(h.tags); err == nil {
+			cfg.AddTags(tags...)
+		}
+	}
+	if set["record"] {
+		cfg.Record = h.record == "on"
+	}
+	return nil
+}
+
+// checkJumpHost 跳板机必须是缓存中的另一个主机, 有多个账号时可以写成 user@host
+func checkJumpHost(cfgs []config.SSHConfig, cfg *config.SSHConfig) error {
+	if cfg.ProxyJump == "" {
+		return nil
+	}
+	user, host, ok := strings.Cut(cfg.ProxyJump, "@")
+	if !ok {
+		user, host = "", cfg.ProxyJump
+	}
+	if host == cfg.Host {
+		return fmt.Errorf("host %s cannot jump through itself", cfg.Host)
+	}
+	for _, c := range cfgs {
+		if c.Host == host && (user == "" || c.User == user) {
+			return nil
+		}
+	}
+	return fmt.Errorf("jump host %s is not cached", cfg.ProxyJump)
+}
+
+// lookupHost 按 -list 的序号、Host、HostName 或 user@host 查找缓存的主机, 匹配到多个账号时报错
+func lookupHost(cfgs []config.SSHConfig, arg string) (*config.SSHConfig, error) {
+	if isInt(arg) {
+		index, _ := strconv.Atoi(arg)
+		if index < 0 || index >= len(cfgs) {
+			return nil, fmt.Errorf("invalid index, out of range: %d", index)
+		}
+		c := cfgs[index]
+		return &c, nil
+	}
+	t := &config.SSHConfig{Host: arg, Hostname: arg}
+	if user, host, ok := strings.Cut(arg, "@"); ok {
+		t = &config.SSHConfig{Hostname: host, User: user}
+	}
+	matches := config.FindSSHConfigs(cfgs, t)
+	switch len(matches) {
+	case 0:
+		return nil, fmt.Errorf("host %s is not cached", arg)
+	case 1:
+		return &matches[0], nil
+	}
+	var accounts []string
+	for _, c := range matches {
+		accounts = append(accounts, c.User+"@"+c.Address())
+	}
+	return nil, fmt.Errorf("host %s has %d accounts: %s, use user@host or the index of ssp list", arg, len(matches), strings.Join(accounts, ", "))
+}
+
+// parseAddArgs 解析 ssp add [options] <host> [[user@]hostname]
+func parseAddArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("add", flag.ExitOnError)
+	fields := newHostFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp add [options] <host> [[user@]hostname]\n")
+		fmt.Fprintf(fs.Output(), "  Add a host to the cache without logging in, user defaults to root (e.g., ssp add -port 2222 -password-stdin node1 root@10.0.0.1 < pass.txt)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	cfg := &config.SSHConfig{Host: fs.Arg(0)}
+	if fs.NArg() == 2 {
+		cfg.Hostname = fs.Arg(1)
+		if user, hostname, ok := strings.Cut(fs.Arg(1), "@"); ok {
+			cfg.User, cfg.Hostname = user, hostname
+		}
+	}
+	if err := fields.validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if cfg.Hostname == "" && fields.hostname == "" {
+		fmt.Println("Hostname is required, e.g. ssp add node1 root@10.0.0.1")
+		os.Exit(1)
+	}
+
+	data["config"] = cfg
+	data["fields"] = fields
+	return "add", data
+}
+
+// parseRmArgs 解析 ssp rm [-y] <host|index|@group>...
+func parseRmArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("rm", flag.ExitOnError)
+	yes := fs.Bool("y", false, "Do not ask for confirmation when removing a group")
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp rm [-y] <host|index|@group>...\n")
+		fmt.Fprintf(fs.Output(), "  Remove hosts from the cache, groups and patterns ask for confirmation (e.g., ssp rm node1 3, ssp rm @env=old)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	for _, arg := range fs.Args() {
+		if !isInt(arg) {
+			parseSelectors([]string{arg})
+		}
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["targets"] = fs.Args()
+	data["yes"] = *yes
+	return "rm", data
+}
+
+// parseEditArgs 解析 ssp edit [options] [host|index], 不指定字段时在编辑器中修改
+func parseEditArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("edit", flag.ExitOnError)
+	fields := newHostFlags(fs)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp edit [options] [host|index]\n")
+		fmt.Fprintf(fs.Output(), "  Change fields of a cached host, fields not given are kept (e.g., ssp edit -port 2222 node1)\n")
+		fmt.Fprintf(fs.Output(), "  Without options open the host, or the whole cache without a host, in $VISUAL or $EDITOR (e.g., ssp edit node1)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 || (fs.NArg() == 0 && len(fields.set()) > 0) {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if err := fields.validate(); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["target"] = fs.Arg(0)
+	if len(fields.set()) > 0 {
+		data["fields"] = fields
+	}
+	return "edit", data
+}
+
+// parseShowArgs 解析 ssp show <host|index>
+func parseShowArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp show <host|index>\n")
+		fmt.Fprintf(fs.Output(), "  Show all cached fields of a host, the password is masked (e.g., ssp show node1)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["target"] = fs.Arg(0)
+	return "show", data
+}
+
+// parseMvArgs 解析 ssp mv <old> <new>
+func parseMvArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("mv", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp mv <host|index> <new-host>\n")
+		fmt.Fprintf(fs.Output(), "  Rename a cached host, ProxyJump of other hosts follows the new name (e.g., ssp mv node1 db1)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	if isInt(fs.Arg(1)) || strings.ContainsAny(fs.Arg(1), " \t@*?[]") {
+		fmt.Printf("Invalid host name %q\n", fs.Arg(1))
+		os.Exit(1)
+	}
+
+	data["config"] = &config.SSHConfig{}
+	data["target"] = fs.Arg(0)
+	data["name"] = fs.Arg(1)
+	return "mv", data
+}
+
+// parseListArgs 解析 ssp list [tags], 与 ssp -list 相同
+func parseListArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: ssp list [tags]\n")
+		fmt.Fprintf(fs.Output(), "  List cached hosts, optionally filtered by tag expression (e.g., ssp list env=prod,!legacy)\n")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() > 1 {
+		fs.Usage()
+		os.Exit(1)
+	}
+	return listArgs(fs.Args(), data)
+}
+
+func listArgs(args []string, data map[string]interface{}) (string, map[string]interface{}) {
+	data["config"] = &config.SSHConfig{}
+	if len(args) > 0 {
+		filter := strings.TrimPrefix(args[0], "@")
+		if _, err := config.ParseTagFilter(filter); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		data["filter"] = filter
+	}
+	return "list", data
+}
+
+// addHost 添加主机, Host 已存在时不覆盖
+func addHost(cfg *config.SSHConfig, fields *hostFlags) {
+	if err := fields.apply(cfg); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if cfg.User == "" {
+		cfg.User = "root"
+	}
+	_, err := config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+		for _, c := range *latest {
+			if c.Key() == cfg.Key() {
+				return fmt.Errorf("%s@%s already exists as %s, use ssp edit to change it", cfg.User, cfg.Address(), cfg.Host)
+			}
+		}
+		if err := checkJumpHost(*latest, cfg); err != nil {
+			return err
+		}
+		*latest = append(*latest, *cfg)
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error adding host: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Added %s (%s@%s)\n", cfg.Host, cfg.User, cfg.Address())
+}
+
+// removeHosts 删除主机, 包含分组或通配符时先确认
+func removeHosts(cfgs []config.SSHConfig, targets []string, yes bool) {
+	remove := map[string]bool{}
+	confirm := false
+	for _, target := range targets {
+		if isInt(target) {
+			c, err := lookupHost(cfgs, target)
+			if err != nil {
+				fmt.Println(err)
+				os.Exit(1)
+			}
+			remove[c.Key()] = true
+			continue
+		}
+		sel := parseSelectors([]string{target})[0]
+		matched := 0
+		for _, c := range cfgs {
+			if sel.Match(&c) {
+				remove[c.Key()] = true
+				matched++
+			}
+		}
+		// 分组、通配符以及有多个账号的主机先确认
+		confirm = confirm || sel.IsGroup() || strings.ContainsAny(target, "*?[") || matched > 1
+		if matched == 0 {
+			fmt.Printf("No cached hosts match %s\n", target)
+			os.Exit(1)
+		}
+	}
+
+	var hosts []string
+	for _, c := range cfgs {
+		if remove[c.Key()] {
+			hosts = append(hosts, c.User+"@"+c.Host)
+		}
+	}
+	if confirm && !yes {
+		fmt.Printf("Delete %d hosts: %s? [y/N] ", len(hosts), strings.Join(hosts, ", "))
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if !strings.EqualFold(strings.TrimSpace(answer), "y") {
+			fmt.Println("Cancelled")
+			return
+		}
+	}
+
+	// 在锁内按账号删除, 避免覆盖其它 ssp 同时写入的记录
+	deleted := 0
+	_, err := config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+		kept := (*latest)[:0]
+		for _, c := range *latest {
+			if remove[c.Key()] {
+				deleted++
+				continue
+			}
+			kept = append(kept, c)
+		}
+		*latest = kept
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error writing cache config: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Deleted %d hosts\n", deleted)
+}
+
+// editHost 修改主机的字段, 按账号在锁内修改
+func editHost(cfgs []config.SSHConfig, target string, fields *hostFlags) {
+	current, err := lookupHost(cfgs, target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	edited := *current
+	if err := fields.apply(&edited); err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+
+	_, err = config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+		if err := checkJumpHost(*latest, &edited); err != nil {
+			return err
+		}
+		for _, c := range *latest {
+			if c.Key() == edited.Key() && c.Key() != current.Key() {
+				return fmt.Errorf("%s@%s already exists as %s", edited.User, edited.Address(), edited.Host)
+			}
+		}
+		for i := range *latest {
+			if (*latest)[i].Key() == current.Key() {
+				// 以文件中最新的登录统计、主机密钥和检查结果为准, 地址变化后旧地址的主机密钥和检查结果不再适用
+				edited.LoginTimes = (*latest)[i].LoginTimes
+				edited.LastLoginTime = (*latest)[i].LastLoginTime
+				edited.HostKey = (*latest)[i].HostKey
+				edited.LastCheck = (*latest)[i].LastCheck
+				if edited.Address() != current.Address() {
+					edited.HostKey = ""
+					edited.LastCheck = config.CheckState{}
+				}
+				(*latest)[i].Update(&edited)
+				return nil
+			}
+		}
+		return fmt.Errorf("host %s is not cached", current.Host)
+	})
+	if err != nil {
+		fmt.Printf("Error editing host: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Updated %s\n", edited.Host)
+}
+
+// showHost 以缓存文件的格式输出主机, 不显示密码
+func showHost(cfgs []config.SSHConfig, target string) {
+	c, err := lookupHost(cfgs, target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	if c.Password != "" {
+		c.Password = "********"
+	}
+	fmt.Print(c.String())
+}
+
+// moveHost 重命名主机的一个账号, 引用它作为跳板机的主机一起修改
+func moveHost(cfgs []config.SSHConfig, target, name string) {
+	current, err := lookupHost(cfgs, target)
+	if err != nil {
+		fmt.Println(err)
+		os.Exit(1)
+	}
+	old := current.Host
+	renamed := *current
+	renamed.Host = name
+	jumps := 0
+	_, err = config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+		found, shared := false, false
+		for _, c := range *latest {
+			if c.Key() == renamed.Key() {
+				return fmt.Errorf("%s@%s already exists as %s", renamed.User, renamed.Address(), name)
+			}
+			found = found || c.Key() == current.Key()
+			shared = shared || (c.Host == old && c.Key() != current.Key())
+		}
+		if !found {
+			return fmt.Errorf("host %s is not cached", old)
+		}
+		for i := range *latest {
+			c := &(*latest)[i]
+			if c.Key() == current.Key() {
+				c.Host = name
+			}
+			// 旧名称还有其它账号时, 只修改写成 user@host 的 ProxyJump
+			user, host, ok := strings.Cut(c.ProxyJump, "@")
+			switch {
+			case ok && host == old && user == current.User:
+				c.ProxyJump = user + "@" + name
+			case !ok && c.ProxyJump == old && !shared:
+				c.ProxyJump = name
+			default:
+				continue
+			}
+			jumps++
+		}
+		return nil
+	})
+	if err != nil {
+		fmt.Printf("Error renaming host: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Renamed %s to %s\n", old, name)
+	if jumps > 0 {
+		fmt.Printf("Updated ProxyJump of %d hosts\n", jumps)
+	}
+}
+
+// editorCommand 依次使用 $VISUAL、$EDITOR, 都没有设置时使用 vi
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.TrimSpace(os.Getenv(env)); editor != "" {
+			return editor
+		}
+	}
+	return "vi"
+}
+
+// runEditor 编辑器命令可以带参数, 如 EDITOR="code -w"
+func runEditor(path string) error {
+	cmd := exec.Command("sh", "-c", editorCommand()+` "$1"`, "sh", path)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	return cmd.Run()
+}
+
+// editInEditor 在编辑器中修改一个主机, target 为空时修改整个缓存
+// 保存后按缓存文件的规则校验, 有错误时提示行号并重新打开编辑器, 校验通过才写回
+func editInEditor(cfgs []config.SSHConfig, target string) {
+	scope := cfgs
+	if target != "" {
+		c, err := lookupHost(cfgs, target)
+		if err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+		scope = []config.SSHConfig{*c}
+	}
+
+	// 临时文件包含密码, 退出前删除
+	if err := editEntries(cfgs, scope); err != nil {
+		fmt.Printf("Error editing hosts: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func editEntries(cfgs []config.SSHConfig, scope []config.SSHConfig) error {
+	file, err := os.CreateTemp("", "ssp-edit-*.conf")
+	if err != nil {
+		return err
+	}
+	path := file.Name()
+	file.Close()
+	defer os.Remove(path)
+
+	if err := config.WriteEditFile(path, scope); err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var edited []config.SSHConfig
+	for {
+		if err := runEditor(path); err != nil {
+			return fmt.Errorf("run editor %s: %w", editorCommand(), err)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(content, original) {
+			fmt.Println("No changes")
+			return nil
+		}
+		edited, err = config.ReadEditFile(path)
+		if err == nil {
+			err = checkEdited(cfgs, scope, edited)
+		}
+		if err == nil {
+			break
+		}
+		fmt.Println(err)
+		fmt.Print("Edit again? [Y/n] ")
+		answer, readErr := bufio.NewReader(os.Stdin).ReadString('\n')
+		if readErr != nil || strings.EqualFold(strings.TrimSpace(answer), "n") {
+			fmt.Println("Cancelled, the cache is not changed")
+			return nil
+		}
+	}
+	if len(edited) == 0 {
+		fmt.Println("Empty file, the cache is not changed")
+		return nil
+	}
+
+	originals := map[string]config.SSHConfig{}
+	for _, c := range scope {
+		originals[c.Key()] = c
+	}
+	_, err = config.UpdateConfig(cacheConfigPath, func(latest *[]config.SSHConfig) error {
+		var kept []config.SSHConfig
+		current := map[string]config.SSHConfig{}
+		for _, c := range *latest {
+			if _, ok := originals[c.Key()]; ok {
+				current[c.Key()] = c
+				continue
+			}
+			kept = append(kept, c)
+		}
+		for i := range edited {
+			e := &edited[i]
+			// 登录统计没有手动修改时以文件中最新的为准
+			if orig, ok := originals[e.Key()]; ok && e.LoginTimes == orig.LoginTimes && e.LastLoginTime.Equal(orig.LastLoginTime) {
+				if c, ok := current[e.Key()]; ok {
+					e.LoginTimes, e.LastLoginTime = c.LoginTimes, c.LastLoginTime
+				}
+			}
+		}
+		if err := checkEdited(*latest, scope, edited); err != nil {
+			return err
+		}
+		*latest = append(kept, edited...)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changed, added := 0, 0
+	for _, e := range edited {
+		orig, ok := originals[e.Key()]
+		switch {
+		case !ok:
+			added++
+		case !orig.Equals(&e):
+			changed++
+		}
+		delete(originals, e.Key())
+	}
+	fmt.Printf("Saved: %d changed, %d added, %d removed\n", changed, added, len(originals))
+	return nil
+}
+
+// checkEdited 检查编辑后的账号与缓存中其它账号不重复, 跳板机都存在, 其它主机使用的跳板机没有被删除或改名
+func checkEdited(cfgs []config.SSHConfig, scope []config.SSHConfig, edited []config.SSHConfig) error {
+	inScope := map[string]bool{}
+	for _, c := range scope {
+		inScope[c.Key()] = true
+	}
+	var others []config.SSHConfig
+	existing := map[string]bool{}
+	for _, c := range cfgs {
+		if !inScope[c.Key()] {
+			others = append(others, c)
+			existing[c.Key()] = true
+		}
+	}
+
+	var errs []error
+	all := append(others, edited...)
+	for _, c := range others {
+		if checkJumpHost(cfgs, &c) == nil && checkJumpHost(all, &c) != nil {
+			errs = append(errs, fmt.Errorf("jump host %s of %s cannot be removed or renamed", c.ProxyJump, c.Host))
+		}
+	}
+	for i := range edited {
+		if existing[edited[i].Key()] {
+			errs = append(errs, fmt.Errorf("%s@%s already exists as %s", edited[i].User, edited[i].Address(), edited[i].Host))
+		}
+		if err := checkJumpHost(all, &edited[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}